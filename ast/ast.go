@@ -2,15 +2,20 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey-interpreter/token"
+	"strconv"
 	"strings"
 )
 
 // Node è l'interfaccia di base per tutti i nodi nell'AST (Albero Sintattico Astratto).
-// Ogni nodo deve implementare i metodi TokenLiteral e String.
+// Ogni nodo deve implementare i metodi TokenLiteral e String, e riportare con Pos ed End
+// l'intervallo di sorgente che occupa (End è la posizione subito dopo l'ultimo carattere).
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
 }
 
 // Statement rappresenta un nodo nell'AST che esprime una dichiarazione.
@@ -31,6 +36,9 @@ type Expression interface {
 // È il punto di partenza per l'intero programma analizzato.
 type Program struct {
 	Statements []Statement
+	// Comments raccoglie, in ordine, tutti i commenti del sorgente. È popolato solo se il
+	// lexer conserva i commenti; i singoli commenti sono anche nel Trivia del token che seguono.
+	Comments []token.Token
 }
 
 // TokenLiteral restituisce il valore letterale del token associato al primo statement nel programma.
@@ -42,6 +50,20 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // String restituisce l'intero programma sotto forma di stringa leggibile.
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -63,6 +85,20 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Name != nil {
+		return ls.Name.End()
+	}
+	return ls.Token.End
+}
+
 // String restituisce la dichiarazione let in formato stringa.
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -80,12 +116,45 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token // il token dell'identificatore
 	Value string      // il nome della variabile o funzione
+	// Binding è la posizione della variabile calcolata dal resolver; nil finché
+	// il programma non è stato risolto, e in quel caso la si cerca per nome.
+	Binding *Binding
+}
+
+// BindingScope indica in quale tipo di scope è dichiarata una variabile risolta.
+type BindingScope int
+
+const (
+	LocalBinding    BindingScope = iota // Nella funzione (o nel blocco catch) corrente
+	CapturedBinding                     // In una funzione esterna, catturata dalla chiusura
+	GlobalBinding                       // Nel programma, fuori da ogni funzione
+	BuiltinBinding                      // Una funzione built-in: non occupa slot
+)
+
+// Binding dice dove si trova a runtime la variabile indicata da un identificatore:
+// nello slot Slot dell'ambiente che si raggiunge risalendo Depth ambienti esterni.
+type Binding struct {
+	Scope BindingScope
+	Depth int
+	Slot  int
+	// Shadowed è la variabile omonima di uno scope esterno, a cui il nome si
+	// riferisce finché lo slot è vuoto (il `let` non è ancora stato eseguito);
+	// nil se non ce n'è.
+	Shadowed *Binding
+}
+
+// Scope elenca le variabili dichiarate in una funzione o in un blocco catch,
+// come calcolate dal resolver: Names[i] è la variabile nello slot i.
+type Scope struct {
+	Names []string
 }
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -98,6 +167,8 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position  { return endOf(rs.ReturnValue, rs.Token.End) }
 
 // String restituisce la dichiarazione return in formato stringa.
 func (rs *ReturnStatement) String() string {
@@ -119,6 +190,8 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return posOf(es.Expression, es.Token.Pos) }
+func (es *ExpressionStatement) End() token.Position  { return endOf(es.Expression, es.Token.End) }
 
 // String restituisce l'espressione in formato stringa.
 func (es *ExpressionStatement) String() string {
@@ -132,12 +205,41 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token // il token che rappresenta l'intero
 	Value int64       // il valore numerico
+	Big   *big.Int    // il valore, se non entra in un int64 (in quel caso Value è 0)
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// FloatLiteral rappresenta un numero in virgola mobile nell'AST, es. "3.14" o "1e-9".
+type FloatLiteral struct {
+	Token token.Token // il token che rappresenta il numero
+	Value float64     // il valore numerico
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// StringLiteral rappresenta una stringa letterale, es. "ciao mondo".
+type StringLiteral struct {
+	Token token.Token // il token STRING
+	Value string      // il contenuto della stringa, con le sequenze di escape già decodificate
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+
+// String restituisce la stringa tra doppi apici, così da distinguerla da un identificatore.
+func (sl *StringLiteral) String() string { return strconv.Quote(sl.Value) }
+
 // PrefixExpression rappresenta un'espressione con un operatore prefisso.
 // Esempi: "-5", "!true". Un operatore prefisso viene applicato a un singolo operando.
 type PrefixExpression struct {
@@ -148,6 +250,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return endOf(pe.Right, pe.Token.End) }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -157,6 +261,26 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// PostfixExpression rappresenta un operatore che segue il suo operando, es. "f()?".
+type PostfixExpression struct {
+	Token    token.Token // Il token postfisso, es. "?"
+	Left     Expression  // L'espressione a sinistra dell'operatore
+	Operator string      // L'operatore postfisso, es. "?"
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) Pos() token.Position  { return posOf(pe.Left, pe.Token.Pos) }
+func (pe *PostfixExpression) End() token.Position  { return pe.Token.End }
+func (pe *PostfixExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(pe.Operator)
+	out.WriteString(")")
+	return out.String()
+}
+
 // InfixExpression rappresenta un'espressione con un operatore infisso.
 // Esempi: "5 + 5", "a == b". L'operatore è situato tra due espressioni.
 type InfixExpression struct {
@@ -168,6 +292,8 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return posOf(oe.Left, oe.Token.Pos) }
+func (oe *InfixExpression) End() token.Position  { return endOf(oe.Right, oe.Token.End) }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -178,6 +304,52 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// LogicalExpression rappresenta un'espressione con un operatore logico "&&" o "||".
+// È distinta da InfixExpression perché l'operando destro viene valutato solo se necessario.
+type LogicalExpression struct {
+	Token    token.Token // Il token dell'operatore
+	Left     Expression  // L'espressione a sinistra
+	Operator string      // "&&" oppure "||"
+	Right    Expression  // L'espressione a destra, valutata solo se serve
+}
+
+func (le *LogicalExpression) expressionNode()      {}
+func (le *LogicalExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LogicalExpression) Pos() token.Position  { return posOf(le.Left, le.Token.Pos) }
+func (le *LogicalExpression) End() token.Position  { return endOf(le.Right, le.Token.End) }
+func (le *LogicalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(le.Left.String())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// AssignExpression rappresenta un assegnamento a una variabile già dichiarata o a un
+// elemento di una collezione, es. "x = 5", "arr[0] += 1", "h[\"k\"] = true".
+type AssignExpression struct {
+	Token    token.Token // Il token dell'operatore di assegnamento
+	Target   Expression  // *Identifier oppure *IndexExpression
+	Operator string      // "=", "+=", "-=", "*=", "/=" oppure "%="
+	Value    Expression  // Il valore da assegnare
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return posOf(ae.Target, ae.Token.Pos) }
+func (ae *AssignExpression) End() token.Position  { return endOf(ae.Value, ae.Token.End) }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -185,6 +357,8 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IfExpression struct {
@@ -198,6 +372,20 @@ func (ie *IfExpression) expressionNode() {}
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return ie.Token.End
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -214,12 +402,27 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // il token '{'
 	Statements []Statement // le istruzioni all'interno del blocco
+	RBrace     token.Token // il token '}' di chiusura
 }
 
 func (bs *BlockStatement) statementNode() {}
 
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BlockStatement) End() token.Position {
+	if bs.RBrace.End.IsValid() {
+		return bs.RBrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -231,7 +434,11 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token     // Il token 'fn'
 	Parameters []*Identifier   // Lista dei parametri della funzione
+	Defaults   []Expression    // Valori predefiniti, allineati a Parameters (nil se assente)
+	Rest       *Identifier     // Il parametro variadico finale ("...rest"), se presente
 	Body       *BlockStatement // Il corpo della funzione
+	Name       string          // Il nome a cui è legata con 'let', se presente
+	Scope      *Scope          // Gli slot del frame, calcolati dal resolver (nil se non risolta)
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -240,27 +447,51 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
-func (fl *FunctionLiteral) String() string {
-	var out bytes.Buffer
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
 	}
+	return fl.Token.End
+}
+
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParameterList(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// ParameterList formatta una lista di parametri con i valori predefiniti e
+// l'eventuale parametro variadico, es. "x, y = 10, ...rest".
+func ParameterList(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	list := []string{}
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			list = append(list, p.String()+" = "+defaults[i].String())
+		} else {
+			list = append(list, p.String())
+		}
+	}
+	if rest != nil {
+		list = append(list, "..."+rest.String())
+	}
+	return strings.Join(list, ", ")
+}
+
 type CallExpression struct {
 	Token     token.Token  // Il token '('
 	Function  Expression   // L'identificatore o la funzione letterale
 	Arguments []Expression // Gli argomenti passati alla funzione
+	RParen    token.Token  // Il token ')' di chiusura
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -268,6 +499,8 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position { return posOf(ce.Function, ce.Token.Pos) }
+func (ce *CallExpression) End() token.Position { return closingEnd(ce.RParen, ce.Token.End) }
 
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -284,3 +517,274 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// ArrayLiteral rappresenta un array letterale, es. "[1, 2 * 2, fn(x) { x }]".
+type ArrayLiteral struct {
+	Token    token.Token  // il token '['
+	Elements []Expression // gli elementi dell'array
+	RBracket token.Token  // il token ']' di chiusura
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return closingEnd(al.RBracket, al.Token.End) }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// IndexExpression rappresenta l'accesso a un elemento tramite indice, es. "myArray[1]".
+type IndexExpression struct {
+	Token    token.Token // il token '['
+	Left     Expression  // l'espressione indicizzata
+	Index    Expression  // l'espressione che calcola l'indice
+	RBracket token.Token // il token ']' di chiusura
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return posOf(ie.Left, ie.Token.Pos) }
+func (ie *IndexExpression) End() token.Position  { return closingEnd(ie.RBracket, ie.Token.End) }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// HashPair è una singola coppia chiave/valore all'interno di un HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral rappresenta una mappa letterale, es. {"nome": "Monkey", 1: true}.
+// Le coppie sono conservate nell'ordine in cui compaiono nel sorgente.
+type HashLiteral struct {
+	Token  token.Token // il token '{'
+	Pairs  []HashPair  // le coppie chiave/valore
+	RBrace token.Token // il token '}' di chiusura
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return closingEnd(hl.RBrace, hl.Token.End) }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// WhileStatement rappresenta un ciclo "while (condizione) { ... }".
+type WhileStatement struct {
+	Token     token.Token     // il token 'while'
+	Condition Expression      // la condizione valutata prima di ogni iterazione
+	Body      *BlockStatement // il corpo del ciclo
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+
+func (ws *WhileStatement) End() token.Position {
+	if ws.Body != nil {
+		return ws.Body.End()
+	}
+	return ws.Token.End
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// ForStatement rappresenta un ciclo in stile C: "for (init; condizione; aggiornamento) { ... }".
+// Tutte e tre le parti tra parentesi sono opzionali; una condizione assente vale sempre vero.
+type ForStatement struct {
+	Token     token.Token     // il token 'for'
+	Init      Statement       // opzionale, eseguita una sola volta prima del ciclo
+	Condition Expression      // opzionale, valutata prima di ogni iterazione
+	Update    Statement       // opzionale, eseguita dopo ogni iterazione
+	Body      *BlockStatement // il corpo del ciclo
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+
+func (fs *ForStatement) End() token.Position {
+	if fs.Body != nil {
+		return fs.Body.End()
+	}
+	return fs.Token.End
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Update != nil {
+		out.WriteString(strings.TrimSuffix(fs.Update.String(), ";"))
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// BreakStatement interrompe il ciclo più interno.
+type BreakStatement struct {
+	Token token.Token // il token 'break'
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// ContinueStatement passa direttamente all'iterazione successiva del ciclo più interno.
+type ContinueStatement struct {
+	Token token.Token // il token 'continue'
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+// ThrowStatement lancia un valore, che risale fino al 'try' più vicino.
+type ThrowStatement struct {
+	Token token.Token // il token 'throw'
+	Value Expression  // il valore lanciato
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position  { return endOf(ts.Value, ts.Token.End) }
+
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// TryExpression rappresenta "try { ... } catch (e) { ... } finally { ... }".
+// Almeno uno tra Catch e Finally è presente.
+type TryExpression struct {
+	Token   token.Token     // il token 'try'
+	Block   *BlockStatement // le istruzioni protette
+	Param   *Identifier     // opzionale, il nome a cui legare il valore catturato
+	Catch   *BlockStatement // opzionale, eseguito se Block lancia un errore
+	Finally *BlockStatement // opzionale, eseguito in ogni caso
+	// CatchScope sono gli slot dell'ambiente del blocco catch, calcolati dal
+	// resolver (nil se il programma non è stato risolto).
+	CatchScope *Scope
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+
+func (te *TryExpression) End() token.Position {
+	if te.Finally != nil {
+		return te.Finally.End()
+	}
+	if te.Catch != nil {
+		return te.Catch.End()
+	}
+	return endOf(te.Block, te.Token.End)
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
+// posOf restituisce la posizione iniziale di un nodo, o fallback se il nodo manca
+// (può succedere negli AST prodotti da un parsing con errori).
+func posOf(node Node, fallback token.Position) token.Position {
+	if node == nil {
+		return fallback
+	}
+	return node.Pos()
+}
+
+// endOf restituisce la posizione finale di un nodo, o fallback se il nodo manca.
+func endOf(node Node, fallback token.Position) token.Position {
+	if node == nil {
+		return fallback
+	}
+	return node.End()
+}
+
+// closingEnd restituisce la fine del token di chiusura di un nodo, o fallback se il
+// parser non l'ha raggiunto.
+func closingEnd(closing token.Token, fallback token.Position) token.Position {
+	if closing.End.IsValid() {
+		return closing.End
+	}
+	return fallback
+}
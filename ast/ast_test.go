@@ -23,3 +23,25 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestIsNil(t *testing.T) {
+	var block *BlockStatement
+	tests := []struct {
+		node     Node
+		isNil    bool
+		expected string
+	}{
+		{nil, true, "node"},
+		{block, true, "BlockStatement"},
+		{&Identifier{Value: "x"}, false, "Identifier"},
+	}
+
+	for _, tt := range tests {
+		if got := IsNil(tt.node); got != tt.isNil {
+			t.Errorf("IsNil(%T) = %t, want %t", tt.node, got, tt.isNil)
+		}
+		if got := KindOf(tt.node); got != tt.expected {
+			t.Errorf("KindOf(%T) = %q, want %q", tt.node, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// File: ast/walk.go
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+Inspect visita l'albero con radice node in profondità, nell'ordine del sorgente,
+chiamando f su ogni nodo. Se f restituisce false, i figli di quel nodo non
+vengono visitati. I nodi mancanti (nil, anche se racchiusi in un'interfaccia)
+vengono saltati.
+
+Anche gli identificatori che dichiarano una variabile (il nome di un let, i
+parametri di una funzione, il parametro di un catch) sono visitati.
+*/
+func Inspect(node Node, f func(Node) bool) {
+	if IsNil(node) || !f(node) {
+		return
+	}
+	for _, child := range children(node) {
+		Inspect(child, f)
+	}
+}
+
+/*
+HoistedLets restituisce i nomi dichiarati con `let` nello scope di node, in
+ordine e senza ripetizioni: quelli del corpo di una funzione, di un blocco catch
+o del programma. Le funzioni annidate e i blocchi catch hanno uno scope proprio
+e vengono esclusi. Compilatore e resolver allocano gli slot di questi nomi
+all'ingresso nello scope.
+*/
+func HoistedLets(node Node) []string {
+	var names []string
+	seen := map[string]bool{}
+
+	var visit func(Node) bool
+	visit = func(n Node) bool {
+		switch n := n.(type) {
+		case *FunctionLiteral:
+			return false
+		case *TryExpression:
+			Inspect(n.Block, visit)
+			Inspect(n.Finally, visit)
+			return false
+		case *LetStatement:
+			if n.Name != nil && !seen[n.Name.Value] {
+				seen[n.Name.Value] = true
+				names = append(names, n.Name.Value)
+			}
+		}
+		return true
+	}
+	Inspect(node, visit)
+
+	return names
+}
+
+// IsNil riconosce sia l'interfaccia nil sia un puntatore nil racchiuso in un Node
+// (es. un *BlockStatement nil lasciato da un parsing fallito), che il confronto
+// con nil non vede.
+func IsNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// KindOf descrive il tipo di un nodo, es. "BlockStatement", per i messaggi di errore.
+func KindOf(node Node) string {
+	if node == nil {
+		return "node"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// children restituisce i figli diretti di node, nell'ordine del sorgente.
+func children(node Node) []Node {
+	var nodes []Node
+	add := func(n Node) {
+		if !IsNil(n) {
+			nodes = append(nodes, n)
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *ExpressionStatement:
+		add(node.Expression)
+	case *LetStatement:
+		add(node.Name)
+		add(node.Value)
+	case *ReturnStatement:
+		add(node.ReturnValue)
+	case *ThrowStatement:
+		add(node.Value)
+	case *WhileStatement:
+		add(node.Condition)
+		add(node.Body)
+	case *ForStatement:
+		add(node.Init)
+		add(node.Condition)
+		add(node.Update)
+		add(node.Body)
+	case *PrefixExpression:
+		add(node.Right)
+	case *PostfixExpression:
+		add(node.Left)
+	case *InfixExpression:
+		add(node.Left)
+		add(node.Right)
+	case *LogicalExpression:
+		add(node.Left)
+		add(node.Right)
+	case *AssignExpression:
+		add(node.Target)
+		add(node.Value)
+	case *IfExpression:
+		add(node.Condition)
+		add(node.Consequence)
+		add(node.Alternative)
+	case *TryExpression:
+		add(node.Block)
+		add(node.Param)
+		add(node.Catch)
+		add(node.Finally)
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			add(p)
+			if i < len(node.Defaults) {
+				add(node.Defaults[i])
+			}
+		}
+		add(node.Rest)
+		add(node.Body)
+	case *CallExpression:
+		add(node.Function)
+		for _, a := range node.Arguments {
+			add(a)
+		}
+	case *ArrayLiteral:
+		for _, e := range node.Elements {
+			add(e)
+		}
+	case *IndexExpression:
+		add(node.Left)
+		add(node.Index)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			add(pair.Key)
+			add(pair.Value)
+		}
+	}
+
+	return nodes
+}
@@ -0,0 +1,317 @@
+// File: code/code.go
+
+/*
+Package code definisce il bytecode eseguito dalla macchina virtuale: gli opcode,
+la loro codifica in byte e la mappa che collega ogni istruzione al sorgente.
+*/
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"monkey-interpreter/token"
+	"sort"
+)
+
+// Instructions è una sequenza di istruzioni codificate: un opcode seguito dai suoi operandi.
+type Instructions []byte
+
+// String disassembla le istruzioni, una per riga, es. "0000 OpConstant 1".
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// Opcode identifica un'istruzione della macchina virtuale.
+type Opcode byte
+
+const (
+	// Costanti e valori
+	OpConstant Opcode = iota // Carica la costante all'indice dato
+	OpTrue
+	OpFalse
+	OpNull
+	OpPop // Scarta il valore in cima allo stack
+
+	// Operatori binari: consumano due valori e lasciano il risultato
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpLessEqual
+	OpGreaterThan
+	OpGreaterEqual
+
+	// Operatori prefissi
+	OpMinus
+	OpBang
+	OpBitNot
+
+	// Salti. Gli operandi sono offset assoluti nelle istruzioni della funzione.
+	OpJump
+	OpJumpNotTruthy      // Salta se il valore in cima è falso; lo consuma in ogni caso
+	OpJumpNotTruthyOrPop // Per "&&": se falso salta lasciandolo, altrimenti lo consuma
+	OpJumpTruthyOrPop    // Per "||": se vero salta lasciandolo, altrimenti lo consuma
+	OpJumpNotErrorValue  // Per "?": salta se il valore in cima non è un valore di errore
+	OpJumpIfLocalSet     // Salta se la variabile nello slot dato ha un valore (es. un parametro con argomento)
+	OpJumpIfFreeSet      // Salta se la variabile libera data ha un valore
+
+	// Variabili. Le Set lasciano il valore sullo stack, le Assign falliscono
+	// se la variabile non è ancora stata dichiarata.
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpGetFree
+	OpAssignFree
+	OpCurrent // La lettura di variabile che segue dà il valore attuale per un assegnamento composto
+
+	// Celle: le variabili locali catturate da una chiusura vivono in una cella
+	// condivisa, così che le modifiche siano visibili a tutti.
+	OpMakeCell   // Sostituisce il valore nello slot locale con una cella che lo contiene
+	OpGetCell    // Legge il valore della cella nello slot locale
+	OpSetCell    // Scrive nella cella nello slot locale
+	OpAssignCell // Come OpSetCell, ma fallisce se la variabile non è dichiarata
+	OpLoadCell   // Carica la cella stessa dello slot locale, per una chiusura
+	OpLoadFree   // Carica la cella di una variabile libera, per una chiusura annidata
+
+	// Strutture dati
+	OpArray        // Crea un array dagli N valori in cima allo stack
+	OpHash         // Crea una mappa dalle N coppie chiave/valore in cima allo stack
+	OpIndex        // Legge container[indice]
+	OpIndexCurrent // Legge container[indice] per un assegnamento composto
+	OpSetIndex     // Scrive container[indice] = valore, lasciando il valore
+	OpDup2         // Duplica i due valori in cima allo stack
+
+	// Funzioni
+	OpCall        // Chiama la funzione sotto gli N argomenti in cima allo stack
+	OpTailCall    // Come OpCall, ma riusa il frame corrente; è sempre seguito da OpReturnValue
+	OpReturnValue // Esce dalla funzione restituendo il valore in cima allo stack
+	OpClosure     // Crea una chiusura dalla funzione costante e dalle N celle in cima allo stack
+
+	// Cicli ed eccezioni
+	OpEnterLoop   // Ricorda l'altezza dello stack all'ingresso in un ciclo
+	OpExitLoop    // Dimentica l'ultimo ciclo ricordato
+	OpUnwindLoop  // Riporta lo stack all'altezza dell'ultimo ciclo, per break e continue
+	OpPushHandler // Installa un gestore di errori che riprende all'offset dato
+	OpPopHandler  // Rimuove l'ultimo gestore installato
+	OpThrow       // Lancia il valore in cima allo stack
+	OpRethrow     // Rilancia l'errore catturato in cima allo stack
+	OpCaught      // Trasforma l'errore catturato nel valore visibile dal blocco catch
+)
+
+// Definition descrive un opcode: il nome leggibile e la larghezza in byte di ogni operando.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	OpJump:               {"OpJump", []int{2}},
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpJumpNotErrorValue:  {"OpJumpNotErrorValue", []int{2}},
+	OpJumpIfLocalSet:     {"OpJumpIfLocalSet", []int{1, 2}},
+	OpJumpIfFreeSet:      {"OpJumpIfFreeSet", []int{1, 2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpAssignLocal:  {"OpAssignLocal", []int{1}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpAssignFree:   {"OpAssignFree", []int{1}},
+	OpCurrent:      {"OpCurrent", []int{}},
+
+	OpMakeCell:   {"OpMakeCell", []int{1}},
+	OpGetCell:    {"OpGetCell", []int{1}},
+	OpSetCell:    {"OpSetCell", []int{1}},
+	OpAssignCell: {"OpAssignCell", []int{1}},
+	OpLoadCell:   {"OpLoadCell", []int{1}},
+	OpLoadFree:   {"OpLoadFree", []int{1}},
+
+	OpArray:        {"OpArray", []int{2}},
+	OpHash:         {"OpHash", []int{2}},
+	OpIndex:        {"OpIndex", []int{}},
+	OpIndexCurrent: {"OpIndexCurrent", []int{}},
+	OpSetIndex:     {"OpSetIndex", []int{}},
+	OpDup2:         {"OpDup2", []int{}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpEnterLoop:   {"OpEnterLoop", []int{}},
+	OpExitLoop:    {"OpExitLoop", []int{}},
+	OpUnwindLoop:  {"OpUnwindLoop", []int{}},
+	OpPushHandler: {"OpPushHandler", []int{2}},
+	OpPopHandler:  {"OpPopHandler", []int{}},
+	OpThrow:       {"OpThrow", []int{}},
+	OpRethrow:     {"OpRethrow", []int{}},
+	OpCaught:      {"OpCaught", []int{}},
+}
+
+// Lookup restituisce la definizione di un opcode.
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make codifica un'istruzione: l'opcode seguito dagli operandi in big endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodifica gli operandi di un'istruzione e restituisce quanti byte ha letto.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 legge un operando di due byte.
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 legge un operando di un byte.
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// Location associa le istruzioni a partire da Offset alla porzione di sorgente
+// che le ha generate, per indicare dove si è verificato un errore.
+type Location struct {
+	Offset int
+	Pos    token.Position
+	End    token.Position
+}
+
+// SourceMap è l'elenco delle Location di una funzione, ordinato per Offset.
+type SourceMap []Location
+
+// Lookup restituisce la porzione di sorgente dell'istruzione che inizia a offset.
+func (m SourceMap) Lookup(offset int) (token.Position, token.Position) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}, token.Position{}
+	}
+	return m[i-1].Pos, m[i-1].End
+}
@@ -0,0 +1,976 @@
+// File: compiler/compiler.go
+
+/*
+Package compiler traduce l'AST in bytecode per la macchina virtuale del
+package vm. Il bytecode ha la stessa semantica osservabile dell'evaluator:
+stessi valori, stessi errori, stesse posizioni nei messaggi.
+
+Ogni istruzione e ogni espressione compilata lascia esattamente un valore
+sullo stack (un ciclo lascia `null`, come nell'evaluator); i blocchi scartano
+con OpPop i valori di tutte le istruzioni tranne l'ultima.
+*/
+package compiler
+
+import (
+	"fmt"
+	"monkey-interpreter/ast"
+	"monkey-interpreter/code"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/object"
+	"monkey-interpreter/token"
+	"sort"
+	"strings"
+)
+
+// Limiti imposti dalla larghezza degli operandi delle istruzioni.
+const (
+	maxConstants    = 1 << 16
+	maxInstructions = 1 << 16
+	maxLocals       = 1 << 8
+	maxArguments    = 1 << 8
+)
+
+// Bytecode è il risultato della compilazione di un programma.
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []object.Object
+	// GlobalNames[i] è il nome del globale i: la VM lo usa nei messaggi di errore
+	// e per ripiegare sulle funzioni built-in quando il globale non è definito.
+	GlobalNames []string
+	// LocalNames sono gli slot locali del programma stesso, usati dai blocchi
+	// catch fuori da ogni funzione.
+	LocalNames []string
+}
+
+// binaryOps associa gli operatori binari ai loro opcode.
+var binaryOps = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+}
+
+// prefixOps associa gli operatori prefissi ai loro opcode.
+var prefixOps = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+// span è la porzione di sorgente a cui attribuire le istruzioni emesse.
+type span struct {
+	pos, end token.Position
+}
+
+// loopContext raccoglie i salti di break e continue di un ciclo, da correggere
+// quando se ne conoscono le destinazioni.
+type loopContext struct {
+	tryDepth  int // Quanti try erano aperti all'ingresso nel ciclo
+	breaks    []int
+	continues []int
+}
+
+// tryContext descrive un try aperto: break, continue e return che ne escono
+// devono rimuoverne il gestore ed eseguirne il blocco finally.
+type tryContext struct {
+	handler bool
+	finally *ast.BlockStatement
+}
+
+// compilationScope contiene il codice della funzione in compilazione.
+type compilationScope struct {
+	instructions code.Instructions
+	sourceMap    code.SourceMap
+	loops        []*loopContext
+	trys         []*tryContext
+}
+
+// Compiler compila uno o più programmi. Tabella dei simboli e costanti possono
+// essere condivise tra compilazioni successive (vedi NewWithState), come fa il REPL.
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []compilationScope
+	spans       []span
+	analysis    *scopeAnalysis
+
+	// tail è vero se il nodo che sta per essere compilato è in posizione di
+	// coda nel corpo di una funzione: una chiamata lì diventa OpTailCall.
+	tail bool
+
+	// settled elenca i `let` certamente eseguiti nel punto in compilazione (vedi
+	// SymbolTable.settle); compileStatements dimentica quelli del proprio blocco.
+	settled []settlement
+}
+
+// settlement è un `let` certamente eseguito: la variabile name della tabella table.
+type settlement struct {
+	table *SymbolTable
+	name  string
+}
+
+// New crea un compilatore con una tabella dei globali vuota.
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState crea un compilatore che riprende i globali e le costanti di una
+// compilazione precedente.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []compilationScope{{}},
+		analysis:    newScopeAnalysis(),
+	}
+}
+
+// Bytecode restituisce il risultato dell'ultima compilazione.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.scope().instructions,
+		SourceMap:    c.scope().sourceMap,
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+		LocalNames:   c.symbolTable.MainLocals(),
+	}
+}
+
+// SymbolTable restituisce la tabella dei globali, da passare a NewWithState.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+// Constants restituisce il pool delle costanti, da passare a NewWithState.
+func (c *Compiler) Constants() []object.Object {
+	return c.constants
+}
+
+/*
+Compile traduce node nel codice dello scope corrente. Un *ast.Program termina
+con OpReturnValue, così che la VM restituisca il valore dell'ultima istruzione.
+
+Gli errori di compilazione riguardano AST malformati e programmi che superano i
+limiti del bytecode (es. più di 65536 costanti); i programmi validi per il parser
+non producono errori e segnalano i problemi solo a runtime, come l'evaluator.
+*/
+func (c *Compiler) Compile(node ast.Node) error {
+	tail := c.tail
+	c.tail = false
+	if ast.IsNil(node) {
+		return fmt.Errorf("invalid program: missing %s", ast.KindOf(node))
+	}
+
+	pos, end := evaluator.ErrorSpan(node)
+	c.spans = append(c.spans, span{pos, end})
+	defer func() { c.spans = c.spans[:len(c.spans)-1] }()
+
+	switch node := node.(type) {
+	// Istruzioni
+	case *ast.Program:
+		if err := c.compileStatements(node.Statements, false); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		if n := len(c.symbolTable.MainLocals()); n > maxLocals {
+			return fmt.Errorf("too many local variables: %d", n)
+		}
+		if len(c.scope().instructions) > maxInstructions {
+			return fmt.Errorf("program too large: more than %d bytes of bytecode", maxInstructions)
+		}
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements, tail)
+	case *ast.ExpressionStatement:
+		c.tail = tail
+		return c.Compile(node.Expression)
+	case *ast.LetStatement:
+		if node.Name == nil {
+			return fmt.Errorf("invalid program: let statement without a name")
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol, ok := c.symbolTable.ResolveLocal(node.Name.Value)
+		if !ok {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		c.setSymbol(symbol)
+		if table := c.symbolTable.settle(node.Name.Value); table != nil {
+			c.settled = append(c.settled, settlement{table, node.Name.Value})
+		}
+	case *ast.ReturnStatement:
+		// Come nell'evaluator, l'operando di un return è in posizione di coda se
+		// siamo in una funzione e fuori da ogni try.
+		c.tail = len(c.scopes) > 1 && len(c.scope().trys) == 0
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		return c.emitReturn()
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.WhileStatement:
+		return c.compileLoop(nil, node.Condition, nil, node.Body)
+	case *ast.ForStatement:
+		return c.compileLoop(node.Init, node.Condition, node.Update, node.Body)
+	case *ast.BreakStatement:
+		return c.compileLoopJump(node, true)
+	case *ast.ContinueStatement:
+		return c.compileLoopJump(node, false)
+
+	// Espressioni
+	case *ast.IntegerLiteral:
+		var integer object.Object = object.NewInteger(node.Value)
+		if node.Big != nil {
+			integer = object.IntegerFromBig(node.Big)
+		}
+		return c.emitConstant(integer)
+	case *ast.FloatLiteral:
+		return c.emitConstant(&object.Float{Value: node.Value})
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: node.Value})
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.Identifier:
+		c.emitVariable(c.symbolTable.Candidates(node.Value), c.getSymbol)
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := prefixOps[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator: %s", node.Operator)
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		return c.emitBinary(node.Operator)
+	case *ast.PostfixExpression:
+		if node.Operator != "?" {
+			return fmt.Errorf("unknown operator: %s", node.Operator)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		jump := c.emit(code.OpJumpNotErrorValue, 9999)
+		if err := c.emitReturn(); err != nil {
+			return err
+		}
+		c.patchJump(jump)
+	case *ast.LogicalExpression:
+		return c.compileLogical(node)
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+	case *ast.IfExpression:
+		return c.compileIf(node, tail)
+	case *ast.TryExpression:
+		return c.compileTry(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		if len(node.Arguments) >= maxArguments {
+			return fmt.Errorf("too many arguments: %d", len(node.Arguments))
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		if tail {
+			c.emit(code.OpTailCall, len(node.Arguments))
+			c.emit(code.OpReturnValue)
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs))
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	default:
+		return fmt.Errorf("unknown node type: %T", node)
+	}
+
+	return nil
+}
+
+// compileStatements compila una sequenza di istruzioni lasciando sullo stack
+// solo il valore dell'ultima, o `null` se la sequenza è vuota. Se tail è vero,
+// l'ultima istruzione è in posizione di coda.
+func (c *Compiler) compileStatements(statements []ast.Statement, tail bool) error {
+	// Dopo il blocco i suoi `let` potrebbero non essere stati eseguiti (es. il
+	// corpo di un if o di un ciclo).
+	defer func(mark int) {
+		for _, s := range c.settled[mark:] {
+			delete(s.table.settled, s.name)
+		}
+		c.settled = c.settled[:mark]
+	}(len(c.settled))
+
+	if len(statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+	for i, s := range statements {
+		if i > 0 {
+			c.emit(code.OpPop)
+		}
+		c.tail = tail && i == len(statements)-1
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+compileLoop compila while e for con lo schema:
+
+	init; OpPop; OpEnterLoop
+	cond: condizione; OpJumpNotTruthy fine
+	      corpo; OpPop
+	next: aggiornamento; OpPop; OpJump cond
+	fine: OpExitLoop; OpNull
+
+OpEnterLoop ricorda l'altezza dello stack, a cui break e continue la riportano.
+*/
+func (c *Compiler) compileLoop(init ast.Statement, condition ast.Expression, update ast.Statement, body *ast.BlockStatement) error {
+	if init != nil {
+		if err := c.Compile(init); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+
+	c.emit(code.OpEnterLoop)
+	loop := &loopContext{tryDepth: len(c.scope().trys)}
+	c.scope().loops = append(c.scope().loops, loop)
+
+	start := len(c.scope().instructions)
+	exit := -1
+	if condition != nil {
+		if err := c.Compile(condition); err != nil {
+			return err
+		}
+		exit = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+
+	next := len(c.scope().instructions)
+	if update != nil {
+		if err := c.Compile(update); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.scope().instructions)
+	if exit >= 0 {
+		c.changeOperand(exit, end)
+	}
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+	for _, pos := range loop.continues {
+		c.changeOperand(pos, next)
+	}
+
+	loops := c.scope().loops
+	c.scope().loops = loops[:len(loops)-1]
+
+	c.emit(code.OpExitLoop)
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileLoopJump compila break (isBreak) o continue: esce dai try aperti nel
+// ciclo, riporta lo stack all'altezza d'ingresso e salta.
+func (c *Compiler) compileLoopJump(node ast.Node, isBreak bool) error {
+	loops := c.scope().loops
+	if len(loops) == 0 {
+		return fmt.Errorf("%s outside of a loop", node.TokenLiteral())
+	}
+	loop := loops[len(loops)-1]
+
+	if err := c.emitFinallies(loop.tryDepth); err != nil {
+		return err
+	}
+	c.emit(code.OpUnwindLoop)
+	pos := c.emit(code.OpJump, 9999)
+	if isBreak {
+		loop.breaks = append(loop.breaks, pos)
+	} else {
+		loop.continues = append(loop.continues, pos)
+	}
+	return nil
+}
+
+// emitReturn esce dalla funzione con il valore in cima allo stack, dopo aver
+// eseguito i blocchi finally dei try aperti.
+func (c *Compiler) emitReturn() error {
+	if err := c.emitFinallies(0); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// emitFinallies chiude, dal più interno, i try aperti oltre i primi depth:
+// rimuove i loro gestori ed esegue i loro blocchi finally.
+func (c *Compiler) emitFinallies(depth int) error {
+	trys := c.scope().trys
+	defer func() { c.scope().trys = trys }()
+
+	for i := len(trys) - 1; i >= depth; i-- {
+		ctx := trys[i]
+		if ctx.handler {
+			c.emit(code.OpPopHandler)
+		}
+		if ctx.finally != nil {
+			// Il finally non è protetto dal proprio try.
+			c.scope().trys = trys[:i]
+			if err := c.Compile(ctx.finally); err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+		}
+	}
+	return nil
+}
+
+// compileLogical compila "&&" e "||" in modo cortocircuitato: il salto lascia
+// sullo stack l'operando sinistro quando basta a decidere il risultato.
+func (c *Compiler) compileLogical(node *ast.LogicalExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var jump int
+	switch node.Operator {
+	case "&&":
+		jump = c.emit(code.OpJumpNotTruthyOrPop, 9999)
+	case "||":
+		jump = c.emit(code.OpJumpTruthyOrPop, 9999)
+	default:
+		return fmt.Errorf("unknown operator: %s", node.Operator)
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.patchJump(jump)
+	return nil
+}
+
+// compileIf compila un if; se è in posizione di coda (tail vero), lo sono anche
+// i suoi rami.
+func (c *Compiler) compileIf(node *ast.IfExpression, tail bool) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.tail = tail
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.patchJump(jumpNotTruthy)
+	if node.Alternative != nil {
+		c.tail = tail
+		if err := c.Compile(node.Alternative); err != nil {
+			return err
+		}
+	} else {
+		c.emit(code.OpNull)
+	}
+	c.patchJump(jump)
+	return nil
+}
+
+/*
+compileAssign compila "x = v", "x op= v" e gli assegnamenti con indice.
+
+Per un indice, OpIndexCurrent verifica il contenitore e l'indice (e legge il
+valore corrente per gli operatori composti) prima di valutare il lato destro,
+così che gli errori arrivino nello stesso ordine dell'evaluator.
+*/
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	var operator code.Opcode
+	compound := node.Operator != "="
+	if compound {
+		var ok bool
+		operator, ok = binaryOps[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator: %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		candidates := c.symbolTable.Candidates(target.Value)
+		if compound {
+			c.emit(code.OpCurrent)
+			c.emitVariable(candidates, c.getSymbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(operator)
+		}
+		c.emitVariable(candidates, c.assignSymbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpDup2)
+		c.emit(code.OpIndexCurrent)
+		if !compound {
+			c.emit(code.OpPop)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(operator)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("invalid assignment target: %s", node.Target)
+	}
+	return nil
+}
+
+/*
+compileTry compila try/catch/finally con lo schema:
+
+	       OpPushHandler catch; blocco; OpPopHandler; finally; OpPop; OpJump fine
+	catch: OpCaught; parametro; OpPushHandler rethrow; catch; OpPopHandler; finally; OpPop; OpJump fine
+	rethrow: finally; OpPop; OpRethrow
+	fine:
+
+Senza finally mancano le parti che lo eseguono; senza catch l'errore passa
+direttamente a rethrow.
+*/
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	if node.Block == nil || (node.Catch == nil && node.Finally == nil) {
+		return fmt.Errorf("invalid program: try without catch or finally")
+	}
+
+	handler := c.emit(code.OpPushHandler, 9999)
+	if err := c.compileProtected(node.Block, node.Finally); err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 9999)}
+
+	c.patchJump(handler)
+	if node.Catch != nil {
+		c.emit(code.OpCaught)
+		catchHandler, err := c.compileCatch(node)
+		if err != nil {
+			return err
+		}
+		if node.Finally != nil {
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+			c.patchJump(catchHandler)
+		}
+	}
+
+	if node.Finally != nil {
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		c.emit(code.OpRethrow)
+	}
+
+	for _, jump := range jumps {
+		c.patchJump(jump)
+	}
+	return nil
+}
+
+// compileProtected compila block sotto il gestore appena installato, seguito
+// dal blocco finally se presente.
+func (c *Compiler) compileProtected(block, finally *ast.BlockStatement) error {
+	c.scope().trys = append(c.scope().trys, &tryContext{handler: true, finally: finally})
+	err := c.Compile(block)
+	c.scope().trys = c.scope().trys[:len(c.scope().trys)-1]
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpPopHandler)
+	if finally != nil {
+		if err := c.Compile(finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compileCatch compila il blocco catch nel suo scope, con il valore catturato in
+// cima allo stack. Se c'è un finally, il catch è protetto da un gestore che
+// esegue il finally e rilancia l'errore: ne restituisce la posizione, perché
+// compileTry ne corregga la destinazione.
+func (c *Compiler) compileCatch(node *ast.TryExpression) (int, error) {
+	outer := c.symbolTable
+	c.symbolTable = NewBlockSymbolTable(outer)
+	defer func() { c.symbolTable = outer }()
+
+	var param Symbol
+	if node.Param != nil {
+		param = c.symbolTable.Define(node.Param.Value)
+	}
+	for _, name := range ast.HoistedLets(node.Catch) {
+		c.symbolTable.DefineHoisted(name)
+	}
+	c.emitMakeCells()
+
+	if node.Param != nil {
+		c.setSymbol(param)
+	}
+	c.emit(code.OpPop)
+
+	if node.Finally == nil {
+		return -1, c.Compile(node.Catch)
+	}
+
+	handler := c.emit(code.OpPushHandler, 9999)
+	return handler, c.compileProtected(node.Catch, node.Finally)
+}
+
+/*
+compileFunction compila una funzione letterale nel suo scope e lascia sullo
+stack la chiusura che la esegue.
+
+Tutte le variabili dichiarate con `let` nel corpo ricevono uno slot all'ingresso,
+così che una funzione annidata possa riferirsi a una variabile dichiarata più
+avanti (es. due funzioni mutuamente ricorsive); finché il `let` non viene
+eseguito, il nome continua a riferirsi alla variabile esterna (vedi emitVariable).
+Le variabili catturate da una funzione annidata vivono in una cella, creata
+all'inizio della funzione.
+*/
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	if node.Body == nil {
+		return fmt.Errorf("invalid program: missing BlockStatement")
+	}
+
+	c.enterScope()
+	c.symbolTable.captured = c.analysis.capturedNames(node)
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+	for _, name := range ast.HoistedLets(node.Body) {
+		c.symbolTable.DefineHoisted(name)
+	}
+	c.emitMakeCells()
+
+	// Un parametro senza argomento prende il valore predefinito, calcolato dopo i
+	// parametri precedenti così da poterli usare (es. fn(x, y = x * 2)).
+	required := 0
+	for i := range node.Parameters {
+		if i >= len(node.Defaults) || node.Defaults[i] == nil {
+			required = i + 1
+			continue
+		}
+		symbol, _ := c.symbolTable.ResolveLocal(node.Parameters[i].Value)
+		jump := c.emit(code.OpJumpIfLocalSet, symbol.Index, 9999)
+		if err := c.Compile(node.Defaults[i]); err != nil {
+			return err
+		}
+		c.setSymbol(symbol)
+		c.emit(code.OpPop)
+		c.changeOperands(jump, symbol.Index, len(c.scope().instructions))
+	}
+
+	// L'ultima istruzione del corpo è in posizione di coda: una chiamata lì
+	// riusa il frame invece di aprirne uno nuovo, come nell'evaluator.
+	c.tail = true
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	table := c.symbolTable
+	numLocals := table.NumDefinitions()
+	freeSymbols := table.FreeSymbols
+	instructions, sourceMap := c.leaveScope()
+
+	if numLocals > maxLocals {
+		return fmt.Errorf("too many local variables: %d", numLocals)
+	}
+	if len(freeSymbols) > maxLocals {
+		return fmt.Errorf("too many captured variables: %d", len(freeSymbols))
+	}
+	if len(instructions) > maxInstructions {
+		return fmt.Errorf("function too large: more than %d bytes of bytecode", maxInstructions)
+	}
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		freeNames[i] = s.Name
+		switch {
+		case s.Scope == FreeScope:
+			c.emit(code.OpLoadFree, s.Index)
+		case s.Scope == LocalScope && s.Cell:
+			c.emit(code.OpLoadCell, s.Index)
+		default:
+			return fmt.Errorf("internal error: captured variable %s is not in a cell", s.Name)
+		}
+	}
+
+	source := &object.Function{Parameters: node.Parameters, Defaults: node.Defaults, Rest: node.Rest, Body: node.Body}
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		SourceMap:     sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumRequired:   required,
+		HasRest:       node.Rest != nil,
+		Name:          node.Name,
+		LocalNames:    append([]string(nil), table.Names()...),
+		FreeNames:     freeNames,
+		Source:        source.Inspect(),
+	}
+
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, index, len(freeSymbols))
+	return nil
+}
+
+// emitMakeCells crea le celle delle variabili catturate dichiarate nello scope corrente.
+func (c *Compiler) emitMakeCells() {
+	var cells []int
+	for _, symbol := range c.symbolTable.store {
+		if symbol.Scope == LocalScope && symbol.Cell {
+			cells = append(cells, symbol.Index)
+		}
+	}
+	sort.Ints(cells)
+	for _, index := range cells {
+		c.emit(code.OpMakeCell, index)
+	}
+}
+
+/*
+emitVariable legge (emit = getSymbol) o assegna (emit = assignSymbol) una
+variabile che può trovarsi in una delle candidates (vedi SymbolTable.Candidates).
+Con più candidate usa la prima che ha già un valore:
+
+	      OpJumpIf...Set c0 → l0; OpJumpIf...Set c1 → l1; ...
+	      ultima candidata; OpJump fine
+	l0:   c0; OpJump fine
+	l1:   c1
+	fine:
+
+Un nome mai dichiarato diventa un globale: a runtime potrà essere definito più
+tardi, o essere una funzione built-in.
+*/
+func (c *Compiler) emitVariable(candidates []Symbol, emit func(Symbol)) {
+	last := len(candidates) - 1
+	checks := make([]int, last)
+	for i, s := range candidates[:last] {
+		op := code.OpJumpIfLocalSet
+		if s.Scope == FreeScope {
+			op = code.OpJumpIfFreeSet
+		}
+		checks[i] = c.emit(op, s.Index, 9999)
+	}
+	emit(candidates[last])
+
+	var ends []int
+	for i, s := range candidates[:last] {
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.changeOperands(checks[i], s.Index, len(c.scope().instructions))
+		emit(s)
+	}
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+}
+
+// getSymbol carica il valore di una variabile.
+func (c *Compiler) getSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case s.Cell:
+		c.emit(code.OpGetCell, s.Index)
+	default:
+		c.emit(code.OpGetLocal, s.Index)
+	}
+}
+
+// setSymbol dichiara una variabile con il valore in cima allo stack, lasciandolo lì.
+func (c *Compiler) setSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Cell:
+		c.emit(code.OpSetCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// assignSymbol aggiorna una variabile già dichiarata con il valore in cima allo stack.
+func (c *Compiler) assignSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpAssignFree, s.Index)
+	case s.Cell:
+		c.emit(code.OpAssignCell, s.Index)
+	default:
+		c.emit(code.OpAssignLocal, s.Index)
+	}
+}
+
+func (c *Compiler) emitBinary(operator string) error {
+	op, ok := binaryOps[operator]
+	if !ok {
+		return fmt.Errorf("unknown operator: %s", operator)
+	}
+	c.emit(op)
+	return nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) >= maxConstants {
+		return 0, fmt.Errorf("too many constants: more than %d", maxConstants)
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+// emit aggiunge un'istruzione allo scope corrente e ne restituisce la posizione.
+// La mappa del sorgente registra la porzione del nodo in compilazione quando cambia.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scope()
+	pos := len(scope.instructions)
+
+	if len(c.spans) > 0 {
+		current := c.spans[len(c.spans)-1]
+		n := len(scope.sourceMap)
+		if n == 0 || scope.sourceMap[n-1].Pos != current.pos || scope.sourceMap[n-1].End != current.end {
+			scope.sourceMap = append(scope.sourceMap, code.Location{Offset: pos, Pos: current.pos, End: current.end})
+		}
+	}
+
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	return pos
+}
+
+// changeOperands riscrive gli operandi dell'istruzione in posizione pos.
+func (c *Compiler) changeOperands(pos int, operands ...int) {
+	ins := c.scope().instructions
+	op := code.Opcode(ins[pos])
+	copy(ins[pos:], code.Make(op, operands...))
+}
+
+// changeOperand riscrive l'operando di un salto.
+func (c *Compiler) changeOperand(pos int, operand int) {
+	c.changeOperands(pos, operand)
+}
+
+// patchJump fa puntare il salto in posizione pos all'istruzione successiva.
+func (c *Compiler) patchJump(pos int) {
+	c.changeOperand(pos, len(c.scope().instructions))
+}
+
+func (c *Compiler) scope() *compilationScope {
+	return &c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, compilationScope{})
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.SourceMap) {
+	scope := c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbolTable = c.symbolTable.Outer
+	return scope.instructions, scope.sourceMap
+}
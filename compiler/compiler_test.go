@@ -0,0 +1,225 @@
+package compiler
+
+import (
+	"monkey-interpreter/ast"
+	"monkey-interpreter/code"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func concatInstructions(s ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected code.Instructions
+	}{
+		{
+			"1 + 2",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"let x = 1; x",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"if (true) { 10 }",
+			concatInstructions(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"a && b",
+			concatInstructions(
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpJumpNotTruthyOrPop, 9),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"",
+			concatInstructions(
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+		got := c.Bytecode().Instructions
+		if got.String() != tt.expected.String() {
+			t.Errorf("%q: wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCompileClosure(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse("fn(x) { let y = x; fn() { y } }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	constants := c.Bytecode().Constants
+
+	outer, ok := constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 1 is not a function: %T", constants[1])
+	}
+	inner := constants[0].(*object.CompiledFunction)
+
+	if outer.NumLocals != 2 || outer.NumParameters != 1 || outer.NumRequired != 1 {
+		t.Errorf("wrong outer function layout: %+v", outer)
+	}
+	// y è catturata da una chiusura: va in una cella creata all'ingresso.
+	if !strings.HasPrefix(outer.Instructions.String(), "0000 OpMakeCell 1\n") {
+		t.Errorf("outer function must create y's cell first. got=\n%s", outer.Instructions)
+	}
+	if !strings.Contains(outer.Instructions.String(), "OpLoadCell 1\n") {
+		t.Errorf("outer function must pass y's cell to the closure. got=\n%s", outer.Instructions)
+	}
+	expected := concatInstructions(
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpReturnValue),
+	)
+	if inner.Instructions.String() != expected.String() {
+		t.Errorf("wrong inner instructions.\nwant=\n%s\ngot=\n%s", expected, inner.Instructions)
+	}
+	if len(inner.FreeNames) != 1 || inner.FreeNames[0] != "y" {
+		t.Errorf("wrong free names: %v", inner.FreeNames)
+	}
+}
+
+func TestCompileTailCalls(t *testing.T) {
+	tests := []struct {
+		input string
+		tail  int // Quante OpTailCall deve contenere la funzione
+		calls int // Quante OpCall
+	}{
+		{"fn(f) { f(1) }", 1, 0},
+		{"fn(f) { if (true) { f(1) } else { f(2) } }", 2, 0},
+		{"fn(f) { while (true) { return f(1) } }", 1, 0},
+		{"fn(f) { f(1); 2 }", 0, 1},
+		{"fn(f) { 1 + f(1) }", 0, 1},
+		{"fn(f) { try { return f(1) } catch (e) { 0 } }", 0, 1},
+		{"fn(f) { try { 0 } catch (e) { return f(1) } }", 1, 0},
+		{"fn(f) { try { 0 } catch (e) { return f(1) } finally { 1 } }", 0, 1},
+		{"fn(f) { try { 0 } finally { return f(1) } }", 2, 0},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+		fn := c.Bytecode().Constants[len(c.Bytecode().Constants)-1].(*object.CompiledFunction)
+		listing := fn.Instructions.String()
+		if got := strings.Count(listing, "OpTailCall"); got != tt.tail {
+			t.Errorf("%q: wrong number of tail calls. want=%d, got=%d\n%s", tt.input, tt.tail, got, listing)
+		}
+		if got := strings.Count(listing, "OpCall"); got != tt.calls {
+			t.Errorf("%q: wrong number of calls. want=%d, got=%d\n%s", tt.input, tt.calls, got, listing)
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse("let x = 1;\nx + true")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := c.Bytecode()
+
+	// OpAdd è l'istruzione che fallisce: deve puntare all'operatore.
+	addAt := 0
+	for addAt < len(bytecode.Instructions) && code.Opcode(bytecode.Instructions[addAt]) != code.OpAdd {
+		def, _ := code.Lookup(bytecode.Instructions[addAt])
+		_, read := code.ReadOperands(def, bytecode.Instructions[addAt+1:])
+		addAt += 1 + read
+	}
+	if addAt == len(bytecode.Instructions) {
+		t.Fatalf("OpAdd not found")
+	}
+	pos, _ := bytecode.SourceMap.Lookup(addAt)
+	if pos.Line != 2 || pos.Column != 3 {
+		t.Errorf("wrong position for OpAdd: %s", pos)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{}}}, "invalid program: missing node"},
+		{&ast.Program{Statements: []ast.Statement{&ast.LetStatement{Value: &ast.Boolean{}}}}, "invalid program: let statement without a name"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(tt.node)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. got=%v, want=%q", err, tt.expected)
+		}
+	}
+}
+
+// TestCompileShadowedLets verifica che un nome cerchi la variabile esterna solo
+// dove il suo let potrebbe non essere ancora stato eseguito.
+func TestCompileShadowedLets(t *testing.T) {
+	tests := []struct {
+		input  string
+		checks int // Quante OpJumpIfLocalSet/OpJumpIfFreeSet deve contenere la funzione
+	}{
+		{"fn() { let x = 1; x }", 0},
+		{"fn(x) { x; let x = 1; x }", 0},
+		{"fn() { x; let x = 1; x }", 1},
+		{"fn() { x += 1; let x = 1 }", 2},
+		{"fn() { if (true) { let x = 1 } x }", 1},
+		{"fn() { while (true) { x; let x = 1; x } }", 1},
+		{"fn() { for (let i = 0; i < 3; i += 1) { i } }", 0},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		if err := c.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+		fn := c.Bytecode().Constants[len(c.Bytecode().Constants)-1].(*object.CompiledFunction)
+		listing := fn.Instructions.String()
+		if got := strings.Count(listing, "OpJumpIfLocalSet") + strings.Count(listing, "OpJumpIfFreeSet"); got != tt.checks {
+			t.Errorf("%q: wrong number of checks. want=%d, got=%d\n%s", tt.input, tt.checks, got, listing)
+		}
+	}
+}
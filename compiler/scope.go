@@ -0,0 +1,108 @@
+// File: compiler/scope.go
+package compiler
+
+import "monkey-interpreter/ast"
+
+/*
+Prima di compilare una funzione servono due informazioni sul suo corpo:
+  - quali nomi dichiara con `let` (ast.HoistedLets): gli slot vengono allocati
+    tutti all'ingresso, così una chiusura può riferirsi a una variabile
+    dichiarata più avanti, come nell'evaluator;
+  - quali delle sue variabili sono catturate da una chiusura annidata
+    (capturedNames): queste vivono in una cella condivisa.
+*/
+
+// scopeAnalysis calcola le variabili libere delle funzioni, ricordando i
+// risultati: una funzione annidata viene esaminata una volta sola.
+type scopeAnalysis struct {
+	free map[*ast.FunctionLiteral]map[string]bool
+}
+
+func newScopeAnalysis() *scopeAnalysis {
+	return &scopeAnalysis{free: map[*ast.FunctionLiteral]map[string]bool{}}
+}
+
+// scan raccoglie i nomi dichiarati e usati nel corpo di fn (esclusi quelli
+// delle funzioni annidate) e le funzioni annidate direttamente in fn. I nomi in
+// bound (parametri e parametri dei catch) hanno sempre un valore; quelli in lets
+// solo dopo l'esecuzione del loro `let`.
+func (a *scopeAnalysis) scan(fn *ast.FunctionLiteral) (bound, lets, used map[string]bool, nested []*ast.FunctionLiteral) {
+	bound = map[string]bool{}
+	lets = map[string]bool{}
+	used = map[string]bool{}
+
+	for _, p := range fn.Parameters {
+		bound[p.Value] = true
+	}
+	if fn.Rest != nil {
+		bound[fn.Rest.Value] = true
+	}
+
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			if n != fn {
+				nested = append(nested, n)
+				return false
+			}
+		case *ast.Identifier:
+			used[n.Value] = true
+		case *ast.LetStatement:
+			if n.Name != nil {
+				lets[n.Name.Value] = true
+			}
+		case *ast.TryExpression:
+			if n.Param != nil {
+				bound[n.Param.Value] = true
+			}
+		}
+		return true
+	})
+
+	return bound, lets, used, nested
+}
+
+/*
+freeNames restituisce i nomi che fn (o una funzione annidata) può leggere da una
+funzione esterna. Un nome dichiarato con `let` in fn vi compare comunque: prima
+che il `let` venga eseguito si riferisce alla variabile omonima esterna (vedi
+SymbolTable.Candidates), che deve quindi essere catturata.
+*/
+func (a *scopeAnalysis) freeNames(fn *ast.FunctionLiteral) map[string]bool {
+	if free, ok := a.free[fn]; ok {
+		return free
+	}
+
+	bound, _, used, nested := a.scan(fn)
+	free := map[string]bool{}
+	for name := range used {
+		if !bound[name] {
+			free[name] = true
+		}
+	}
+	for _, inner := range nested {
+		for name := range a.freeNames(inner) {
+			if !bound[name] {
+				free[name] = true
+			}
+		}
+	}
+
+	a.free[fn] = free
+	return free
+}
+
+// capturedNames restituisce le variabili dichiarate in fn che una funzione
+// annidata usa, e che vanno quindi allocate in una cella.
+func (a *scopeAnalysis) capturedNames(fn *ast.FunctionLiteral) map[string]bool {
+	bound, lets, _, nested := a.scan(fn)
+	captured := map[string]bool{}
+	for _, inner := range nested {
+		for name := range a.freeNames(inner) {
+			if bound[name] || lets[name] {
+				captured[name] = true
+			}
+		}
+	}
+	return captured
+}
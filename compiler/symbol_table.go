@@ -0,0 +1,246 @@
+// File: compiler/symbol_table.go
+package compiler
+
+// SymbolScope indica dove vive una variabile a runtime.
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL" // Nell'array dei globali della VM
+	LocalScope  SymbolScope = "LOCAL"  // In uno slot del frame della funzione
+	FreeScope   SymbolScope = "FREE"   // In una cella catturata dalla chiusura
+)
+
+// Symbol descrive una variabile risolta dal compilatore.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Cell vale solo per LocalScope: la variabile è catturata da una chiusura
+	// e il suo slot contiene una *object.Cell invece del valore.
+	Cell bool
+}
+
+/*
+SymbolTable associa i nomi alle variabili di uno scope. Ogni funzione ha la sua
+tabella, collegata a quella della funzione che la contiene; i blocchi catch
+hanno una tabella "di blocco", che introduce nuovi nomi ma ne alloca gli slot
+nella tabella della funzione (o del programma) a cui appartiene.
+*/
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// FreeSymbols sono le variabili delle funzioni esterne usate da questa,
+	// nell'ordine in cui la chiusura deve catturarle.
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string // names[i] è il nome dello slot i
+	block          bool
+
+	// captured contiene i nomi delle variabili locali catturate da una
+	// chiusura annidata, che vanno quindi allocate in una cella.
+	captured map[string]bool
+
+	// mainLocals sono, nella tabella dei globali, i nomi degli slot locali del
+	// programma: le variabili dei blocchi catch fuori da ogni funzione.
+	mainLocals []string
+
+	// hoisted contiene le variabili dichiarate con `let`: hanno uno slot fin
+	// dall'ingresso, ma finché il loro `let` non viene eseguito il nome si
+	// riferisce ancora alla variabile omonima di uno scope esterno, come
+	// nell'evaluator. settled contiene quelle il cui `let` è stato certamente
+	// eseguito nel punto in compilazione.
+	hoisted map[string]bool
+	settled map[string]bool
+}
+
+// NewSymbolTable crea la tabella dei globali.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol), hoisted: map[string]bool{}, settled: map[string]bool{}}
+}
+
+// NewEnclosedSymbolTable crea la tabella di una funzione definita dentro outer.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable crea la tabella di un blocco con scope proprio dentro outer.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// owner restituisce la tabella che alloca gli slot: quella della funzione o del
+// programma che contiene il blocco.
+func (s *SymbolTable) owner() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+	return s
+}
+
+// Define dichiara name in questo scope. Ridichiarare un nome già presente
+// restituisce lo stesso simbolo, come `let` sovrascrive una variabile esistente.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		return symbol
+	}
+
+	owner := s.owner()
+	if owner.Outer == nil && s.block {
+		// Un blocco catch fuori da ogni funzione ha variabili proprie, distinte
+		// dai globali: vivono in celle negli slot locali del programma, così che
+		// ogni esecuzione del blocco ne abbia di nuove.
+		symbol := Symbol{Name: name, Scope: LocalScope, Index: len(owner.mainLocals), Cell: true}
+		owner.mainLocals = append(owner.mainLocals, name)
+		s.store[name] = symbol
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: owner.numDefinitions}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+		symbol.Cell = owner.captured[name]
+	}
+	owner.numDefinitions++
+	owner.names = append(owner.names, name)
+
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineHoisted dichiara name come variabile di un `let` nel corpo dello scope.
+// Un parametro con lo stesso nome ha sempre un valore, quindi resta tale.
+func (s *SymbolTable) DefineHoisted(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		return symbol
+	}
+	s.hoisted[name] = true
+	return s.Define(name)
+}
+
+// settle registra che il `let` di name è appena stato compilato: da qui in poi
+// la variabile ha certamente un valore. Restituisce la tabella modificata, o nil
+// se non è cambiato nulla.
+func (s *SymbolTable) settle(name string) *SymbolTable {
+	for table := s; ; table = table.Outer {
+		if symbol, ok := table.store[name]; ok && symbol.Scope != FreeScope {
+			if !table.hoisted[name] || table.settled[name] {
+				return nil
+			}
+			table.settled[name] = true
+			return table
+		}
+		if !table.block {
+			return nil
+		}
+	}
+}
+
+// defineFree registra original, che appartiene a una funzione esterna, come
+// variabile libera di questa funzione. Una variabile locale con lo stesso nome
+// mantiene il nome per sé.
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	for i, free := range s.FreeSymbols {
+		if free == original {
+			return Symbol{Name: original.Name, Index: i, Scope: FreeScope}
+		}
+	}
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	if _, ok := s.store[original.Name]; !ok {
+		s.store[original.Name] = symbol
+	}
+	return symbol
+}
+
+// Resolve cerca name in questo scope e in quelli esterni. Una variabile locale di
+// una funzione esterna diventa una variabile libera di ogni funzione attraversata.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope || s.block {
+		return symbol, ok
+	}
+	return s.defineFree(symbol), true
+}
+
+/*
+Candidates restituisce le variabili a cui name può riferirsi a runtime, dalla
+più interna alla più esterna. Una variabile di un `let` non ancora eseguito
+(vedi hoisted) non nasconde quelle omonime degli scope esterni: la lista
+prosegue finché trova un parametro, un `let` certamente eseguito o un globale.
+Un nome mai dichiarato diventa un globale, come in Resolve.
+*/
+func (s *SymbolTable) Candidates(name string) []Symbol {
+	var candidates []Symbol
+	for table := s; table != nil; table = table.Outer {
+		symbol, ok := table.store[name]
+		if !ok || symbol.Scope == FreeScope {
+			continue
+		}
+		candidates = append(candidates, s.capture(table, symbol))
+		if !table.hoisted[name] || table.settled[name] {
+			return candidates
+		}
+	}
+	return append(candidates, s.Global().Define(name))
+}
+
+// capture rende accessibile da questo scope symbol, dichiarato nella tabella
+// table: se appartiene a una funzione esterna diventa una variabile libera di
+// ogni funzione attraversata.
+func (s *SymbolTable) capture(table *SymbolTable, symbol Symbol) Symbol {
+	fn := s.owner()
+	if symbol.Scope == GlobalScope || fn == table.owner() {
+		return symbol
+	}
+	return fn.defineFree(fn.Outer.capture(table, symbol))
+}
+
+// ResolveLocal cerca name solo tra le variabili dichiarate nella funzione
+// corrente (o nel programma), senza risalire alle funzioni esterne.
+func (s *SymbolTable) ResolveLocal(name string) (Symbol, bool) {
+	for table := s; ; table = table.Outer {
+		if symbol, ok := table.store[name]; ok && symbol.Scope != FreeScope {
+			return symbol, true
+		}
+		if !table.block {
+			return Symbol{}, false
+		}
+	}
+}
+
+// Global restituisce la tabella dei globali.
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// NumDefinitions restituisce quanti slot sono stati allocati.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.owner().numDefinitions
+}
+
+// MainLocals restituisce i nomi degli slot locali del programma, nell'ordine degli indici.
+func (s *SymbolTable) MainLocals() []string {
+	return s.Global().mainLocals
+}
+
+// Names restituisce i nomi degli slot allocati, nell'ordine degli indici.
+func (s *SymbolTable) Names() []string {
+	return s.owner().names
+}
@@ -0,0 +1,86 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("wrong global symbol: %+v", a)
+	}
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a name must reuse its slot. got=%+v", again)
+	}
+
+	fn := NewEnclosedSymbolTable(global)
+	fn.captured = map[string]bool{"c": true}
+	b := fn.Define("b")
+	c := fn.Define("c")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong local symbol: %+v", b)
+	}
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 1, Cell: true}) {
+		t.Errorf("captured local must live in a cell: %+v", c)
+	}
+
+	if got, ok := fn.Resolve("a"); !ok || got != a {
+		t.Errorf("globals must resolve unchanged. got=%+v", got)
+	}
+	if _, ok := fn.Resolve("missing"); ok {
+		t.Errorf("unknown name resolved")
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	outer := NewEnclosedSymbolTable(global)
+	outer.captured = map[string]bool{"x": true}
+	outer.Define("x")
+	middle := NewEnclosedSymbolTable(outer)
+	inner := NewEnclosedSymbolTable(middle)
+
+	got, ok := inner.Resolve("x")
+	if !ok || got != (Symbol{Name: "x", Scope: FreeScope, Index: 0}) {
+		t.Fatalf("wrong free symbol: %+v", got)
+	}
+	// La funzione intermedia deve catturare x per poterla passare a inner.
+	if len(middle.FreeSymbols) != 1 || middle.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("middle must capture the outer local. got=%+v", middle.FreeSymbols)
+	}
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0].Scope != FreeScope {
+		t.Errorf("inner must capture middle's free variable. got=%+v", inner.FreeSymbols)
+	}
+	if _, ok := inner.ResolveLocal("x"); ok {
+		t.Errorf("ResolveLocal must ignore free variables")
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	fn := NewEnclosedSymbolTable(global)
+	fn.Define("a")
+
+	block := NewBlockSymbolTable(fn)
+	e := block.Define("e")
+	if e != (Symbol{Name: "e", Scope: LocalScope, Index: 1}) {
+		t.Errorf("block variables take slots of the enclosing function. got=%+v", e)
+	}
+	if got, ok := block.ResolveLocal("a"); !ok || got.Index != 0 {
+		t.Errorf("block must see the function's locals. got=%+v", got)
+	}
+	if _, ok := fn.Resolve("e"); ok {
+		t.Errorf("block variables must not leak into the function")
+	}
+	if fn.NumDefinitions() != 2 {
+		t.Errorf("wrong number of slots. got=%d", fn.NumDefinitions())
+	}
+
+	topBlock := NewBlockSymbolTable(global)
+	top := topBlock.Define("e")
+	if top != (Symbol{Name: "e", Scope: LocalScope, Index: 0, Cell: true}) {
+		t.Errorf("top-level block variables live in the program's cells. got=%+v", top)
+	}
+	if len(global.Names()) != 0 {
+		t.Errorf("top-level block variables must not become globals. got=%v", global.Names())
+	}
+}
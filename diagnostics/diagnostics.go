@@ -0,0 +1,80 @@
+// File: diagnostics/diagnostics.go
+
+/*
+Package diagnostics descrive in modo strutturato i problemi trovati nel codice
+Monkey (dal parser o durante l'esecuzione) e li presenta all'utente: come testo
+con il frammento di sorgente sottolineato, oppure come JSON per altri strumenti.
+*/
+package diagnostics
+
+import "monkey-interpreter/token"
+
+// Severity indica la gravità di una diagnostica.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText fa comparire la gravità come stringa nel JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Span è un intervallo del sorgente: Start è incluso, End è escluso.
+// Se End non è valido, lo span copre un solo carattere.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// SpanOf restituisce lo span di un token.
+func SpanOf(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+// Label è un'annotazione secondaria che indica un altro punto rilevante del
+// sorgente, es. la parentesi aperta che non è stata chiusa.
+type Label struct {
+	Span    Span
+	Message string
+}
+
+// Fix è una correzione suggerita: sostituire il testo in Span con Replacement
+// (uno span vuoto indica un inserimento).
+type Fix struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+// Diagnostic è un singolo problema da segnalare.
+type Diagnostic struct {
+	Severity Severity
+	Code     string // Un identificatore stabile del tipo di problema, es. "P001".
+	Message  string
+	Span     Span    // Il punto principale del problema.
+	Labels   []Label // Altri punti rilevanti.
+	Fix      *Fix    // Una correzione suggerita, se ce n'è una ovvia.
+	Notes    []string
+}
+
+// String restituisce la diagnostica su una riga, nel formato "file:riga:colonna: messaggio".
+func (d Diagnostic) String() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
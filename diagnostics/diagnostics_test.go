@@ -0,0 +1,144 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey-interpreter/token"
+	"testing"
+)
+
+func pos(line, column, offset int) token.Position {
+	return token.Position{Line: line, Column: column, Offset: offset}
+}
+
+func TestRenderText(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		diag     Diagnostic
+		expected string
+	}{
+		{
+			name:   "caret sotto lo span",
+			source: "let x = 5 +;",
+			diag: Diagnostic{
+				Code:    "P002",
+				Message: "expected expression, got `;`",
+				Span:    Span{Start: pos(1, 12, 11), End: pos(1, 13, 12)},
+			},
+			expected: "error[P002]: expected expression, got `;`\n" +
+				" --> 1:12\n" +
+				"  |\n" +
+				"1 | let x = 5 +;\n" +
+				"  |            ^\n",
+		},
+		{
+			name:   "etichetta secondaria e correzione",
+			source: "add(1, 2;",
+			diag: Diagnostic{
+				Code:    "P001",
+				Message: "expected `,` or `)` after argument, got `;`",
+				Span:    Span{Start: pos(1, 9, 8), End: pos(1, 10, 9)},
+				Labels:  []Label{{Span: Span{Start: pos(1, 4, 3), End: pos(1, 5, 4)}, Message: "to match this `(`"}},
+				Fix:     &Fix{Message: "insert `)`", Span: Span{Start: pos(1, 9, 8), End: pos(1, 9, 8)}, Replacement: ")"},
+			},
+			expected: "error[P001]: expected `,` or `)` after argument, got `;`\n" +
+				" --> 1:9\n" +
+				"  |\n" +
+				"1 | add(1, 2;\n" +
+				"  |         ^\n" +
+				"  |    - to match this `(`\n" +
+				"  = help: insert `)`\n",
+		},
+		{
+			name:   "span su più caratteri, tab e righe diverse",
+			source: "if (x {\n\tfoo == 1\n}",
+			diag: Diagnostic{
+				Severity: Warning,
+				Message:  "suspicious comparison",
+				Span:     Span{Start: pos(2, 2, 9), End: pos(2, 5, 12)},
+				Labels:   []Label{{Span: Span{Start: pos(1, 4, 3)}, Message: "here"}},
+				Notes:    []string{"first line\nsecond line"},
+			},
+			expected: "warning: suspicious comparison\n" +
+				" --> 2:2\n" +
+				"  |\n" +
+				"1 | if (x {\n" +
+				"  |    - here\n" +
+				"2 | \tfoo == 1\n" +
+				"  | \t^^^\n" +
+				"  = note: first line\n" +
+				"    second line\n",
+		},
+		{
+			name:     "posizione sconosciuta",
+			source:   "",
+			diag:     Diagnostic{Message: "boom"},
+			expected: "error: boom\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := (TextRenderer{Source: tt.source}).Render(&out, []Diagnostic{tt.diag}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output.\nexpected:\n%s\ngot:\n%s", tt.name, tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderTextColor(t *testing.T) {
+	diag := Diagnostic{Message: "boom", Span: Span{Start: pos(1, 1, 0)}}
+
+	var plain, colored bytes.Buffer
+	(TextRenderer{Source: "x"}).Render(&plain, []Diagnostic{diag})
+	(TextRenderer{Source: "x", Color: true}).Render(&colored, []Diagnostic{diag})
+
+	if bytes.Contains(plain.Bytes(), []byte("\x1b[")) {
+		t.Errorf("plain output contains ANSI escapes: %q", plain.String())
+	}
+	if !bytes.Contains(colored.Bytes(), []byte(ansiRed)) {
+		t.Errorf("colored output has no ANSI escapes: %q", colored.String())
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	diags := []Diagnostic{{
+		Code:    "P001",
+		Message: "expected `)`",
+		Span:    Span{Start: pos(1, 9, 8), End: pos(1, 10, 9)},
+		Labels:  []Label{{Span: Span{Start: pos(1, 4, 3)}, Message: "to match this `(`"}},
+		Fix:     &Fix{Message: "insert `)`", Span: Span{Start: pos(1, 9, 8), End: pos(1, 9, 8)}, Replacement: ")"},
+	}}
+
+	var out bytes.Buffer
+	if err := RenderJSON(&out, diags); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out.String())
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(decoded))
+	}
+
+	d := decoded[0]
+	if d["severity"] != "error" || d["code"] != "P001" || d["message"] != "expected `)`" {
+		t.Errorf("wrong header fields: %v", d)
+	}
+	start := d["span"].(map[string]interface{})["start"].(map[string]interface{})
+	if start["line"] != 1.0 || start["column"] != 9.0 || start["offset"] != 8.0 {
+		t.Errorf("wrong span start: %v", start)
+	}
+	label := d["labels"].([]interface{})[0].(map[string]interface{})
+	if _, ok := label["span"].(map[string]interface{})["end"]; ok {
+		t.Errorf("label without end should omit it: %v", label)
+	}
+	if fix := d["fix"].(map[string]interface{}); fix["replacement"] != ")" {
+		t.Errorf("wrong fix: %v", fix)
+	}
+}
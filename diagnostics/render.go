@@ -0,0 +1,249 @@
+// File: diagnostics/render.go
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey-interpreter/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codici ANSI usati quando il colore è attivo.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// TextRenderer scrive le diagnostiche in forma leggibile, mostrando le righe di
+// sorgente coinvolte con il punto del problema sottolineato.
+type TextRenderer struct {
+	Source string // Il sorgente a cui si riferiscono le posizioni.
+	Color  bool   // Se vero, usa i colori ANSI.
+}
+
+/*
+Render scrive le diagnostiche in w, nel formato:
+
+	error[P001]: expected `)` after argument, got `;`
+	 --> 1:10
+	  |
+	1 | add(1, 2;
+	  |     -    ^ expected `)`
+	  = help: insert `)`
+*/
+func (r TextRenderer) Render(w io.Writer, diags []Diagnostic) error {
+	lines := strings.Split(r.Source, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	var out strings.Builder
+	for _, d := range diags {
+		r.renderOne(&out, lines, d)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// marker è una sottolineatura da disegnare sotto una riga di sorgente.
+type marker struct {
+	span    Span
+	char    string
+	color   string
+	message string
+}
+
+func (r TextRenderer) renderOne(out *strings.Builder, lines []string, d Diagnostic) {
+	severityColor := map[Severity]string{Error: ansiRed, Warning: ansiYellow, Note: ansiCyan}[d.Severity]
+
+	header := d.Severity.String()
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+	out.WriteString(r.paint(ansiBold+severityColor, header) + r.paint(ansiBold, ": "+d.Message) + "\n")
+
+	markers := []marker{{span: d.Span, char: "^", color: severityColor}}
+	for _, label := range d.Labels {
+		markers = append(markers, marker{span: label.Span, char: "-", color: ansiBlue, message: label.Message})
+	}
+
+	// Le righe da mostrare, in ordine, con le sottolineature che le riguardano.
+	byLine := map[int][]marker{}
+	for _, m := range markers {
+		if m.span.Start.IsValid() && m.span.Start.Line <= len(lines) {
+			byLine[m.span.Start.Line] = append(byLine[m.span.Start.Line], m)
+		}
+	}
+	lineNumbers := make([]int, 0, len(byLine))
+	for line := range byLine {
+		lineNumbers = append(lineNumbers, line)
+	}
+	sort.Ints(lineNumbers)
+
+	width := 1
+	if len(lineNumbers) > 0 {
+		width = len(strconv.Itoa(lineNumbers[len(lineNumbers)-1]))
+	}
+	gutter := strings.Repeat(" ", width)
+
+	if d.Span.Start.IsValid() {
+		out.WriteString(gutter + r.paint(ansiBlue, "--> ") + d.Span.Start.String() + "\n")
+	}
+	if len(lineNumbers) > 0 {
+		out.WriteString(gutter + r.paint(ansiBlue, " |") + "\n")
+	}
+	for _, number := range lineNumbers {
+		source := lines[number-1]
+		out.WriteString(r.paint(ansiBlue, fmt.Sprintf("%*d |", width, number)) + " " + source + "\n")
+		for _, m := range byLine[number] {
+			underline := padding(source, m.span.Start.Column) + strings.Repeat(m.char, spanWidth(source, m.span))
+			if m.message != "" {
+				underline += " " + m.message
+			}
+			out.WriteString(gutter + r.paint(ansiBlue, " |") + " " + r.paint(ansiBold+m.color, underline) + "\n")
+		}
+	}
+
+	if d.Fix != nil {
+		help := d.Fix.Message
+		if help == "" && d.Fix.Replacement != "" {
+			help = "replace with `" + d.Fix.Replacement + "`"
+		}
+		out.WriteString(gutter + r.paint(ansiBlue, " = ") + r.paint(ansiBold, "help") + ": " + help + "\n")
+	}
+	for _, note := range d.Notes {
+		for i, line := range strings.Split(note, "\n") {
+			if i == 0 {
+				out.WriteString(gutter + r.paint(ansiBlue, " = ") + r.paint(ansiBold, "note") + ": " + line + "\n")
+			} else {
+				out.WriteString(gutter + "   " + line + "\n")
+			}
+		}
+	}
+}
+
+// padding restituisce lo spazio che precede la colonna column di source,
+// conservando i tab così che la sottolineatura resti allineata.
+func padding(source string, column int) string {
+	var pad strings.Builder
+	col := 1
+	for _, ch := range source {
+		if col >= column {
+			break
+		}
+		if ch == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+		col++
+	}
+	for ; col < column; col++ {
+		pad.WriteRune(' ')
+	}
+	return pad.String()
+}
+
+// spanWidth calcola quanti caratteri sottolineare: fino alla fine dello span se
+// sta sulla stessa riga, altrimenti fino alla fine della riga; almeno uno.
+func spanWidth(source string, span Span) int {
+	width := 1
+	if span.End.IsValid() && span.End.Line == span.Start.Line {
+		width = span.End.Column - span.Start.Column
+	} else if span.End.IsValid() && span.End.Line > span.Start.Line {
+		width = utf8.RuneCountInString(source) - span.Start.Column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	return width
+}
+
+// paint colora s se il colore è attivo.
+func (r TextRenderer) paint(color, s string) string {
+	if !r.Color || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// Le strutture seguenti definiscono il formato JSON, separato dai tipi Go così
+// che rinominare un campo non cambi l'output letto da altri strumenti.
+type jsonPosition struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition  `json:"start"`
+	End   *jsonPosition `json:"end,omitempty"`
+}
+
+type jsonLabel struct {
+	Span    jsonSpan `json:"span"`
+	Message string   `json:"message"`
+}
+
+type jsonFix struct {
+	Message     string   `json:"message"`
+	Span        jsonSpan `json:"span"`
+	Replacement string   `json:"replacement"`
+}
+
+type jsonDiagnostic struct {
+	Severity Severity    `json:"severity"`
+	Code     string      `json:"code,omitempty"`
+	Message  string      `json:"message"`
+	Span     jsonSpan    `json:"span"`
+	Labels   []jsonLabel `json:"labels,omitempty"`
+	Fix      *jsonFix    `json:"fix,omitempty"`
+	Notes    []string    `json:"notes,omitempty"`
+}
+
+// RenderJSON scrive le diagnostiche in w come array JSON, una per elemento.
+func RenderJSON(w io.Writer, diags []Diagnostic) error {
+	out := make([]jsonDiagnostic, 0, len(diags))
+	for _, d := range diags {
+		jd := jsonDiagnostic{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+			Span:     toJSONSpan(d.Span),
+			Notes:    d.Notes,
+		}
+		for _, label := range d.Labels {
+			jd.Labels = append(jd.Labels, jsonLabel{Span: toJSONSpan(label.Span), Message: label.Message})
+		}
+		if d.Fix != nil {
+			jd.Fix = &jsonFix{Message: d.Fix.Message, Span: toJSONSpan(d.Fix.Span), Replacement: d.Fix.Replacement}
+		}
+		out = append(out, jd)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func toJSONSpan(span Span) jsonSpan {
+	js := jsonSpan{Start: toJSONPosition(span.Start)}
+	if span.End.IsValid() {
+		end := toJSONPosition(span.End)
+		js.End = &end
+	}
+	return js
+}
+
+func toJSONPosition(pos token.Position) jsonPosition {
+	return jsonPosition{File: pos.Filename, Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+}
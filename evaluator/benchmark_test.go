@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"monkey-interpreter/ast"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+)
+
+/*
+Benchmark di programmi dominati dall'aritmetica. Con -benchmem mostrano quanto
+allocano: i programmi che restano entro la cache degli interi (contatori, indici,
+fib di numeri piccoli) allocano quasi solo gli ambienti delle chiamate, mentre
+BenchmarkLargeIntegers fa lo stesso lavoro fuori dalla cache e alloca un
+oggetto per ogni risultato.
+*/
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"SmallIntegers", "let i = 0; let x = 0; while (i < 1000) { x = (i % 100) * 3 + 7 - 100; i += 1 }; x"},
+	{"LargeIntegers", "let i = 0; let x = 0; while (i < 1000) { x = (i % 100) * 3 + 7 + 100000; i += 1 }; x"},
+	{"Comparisons", "let i = 0; let n = 0; while (i < 1000) { if (i % 3 == 0 && i != 500) { n += 1 } i += 1 }; n"},
+	{"Fibonacci", "let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib(15)"},
+}
+
+func BenchmarkEval(b *testing.B) {
+	for _, bm := range benchmarks {
+		program := parser.New(lexer.New(bm.input)).ParseProgram()
+		b.Run(bm.name, func(b *testing.B) {
+			benchmarkProgram(b, program)
+		})
+	}
+}
+
+func benchmarkProgram(b *testing.B, program *ast.Program) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result := Run(program, object.NewEnvironment()); isError(result) {
+			b.Fatalf("unexpected error: %s", result.Inspect())
+		}
+	}
+}
+
+// integerSink impedisce al compilatore di eliminare le allocazioni misurate.
+var integerSink object.Object
+
+// BenchmarkNewInteger confronta il costo di un intero della cache con quello di uno nuovo.
+func BenchmarkNewInteger(b *testing.B) {
+	values := []struct {
+		name  string
+		value int64
+	}{
+		{"Cached", 42},
+		{"Allocated", 100000},
+	}
+
+	for _, v := range values {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				integerSink = object.NewInteger(v.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// File: evaluator/builtins.go
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"monkey-interpreter/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtins è il registro delle funzioni native, consultato da evalIdentifier
+// quando un nome non è definito nell'ambiente.
+var builtins = map[string]*object.Builtin{
+	// len restituisce il numero di caratteri di una stringa o di elementi di un array o di una mappa.
+	"len": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *object.String:
+			return object.NewInteger(int64(utf8.RuneCountInString(arg.Value)))
+		case *object.Array:
+			return object.NewInteger(int64(len(arg.Elements)))
+		case *object.Hash:
+			return object.NewInteger(int64(len(arg.Pairs)))
+		default:
+			return newError("argument to `len` not supported, got %s", args[0].Type())
+		}
+	}},
+
+	// puts stampa la rappresentazione di ogni argomento su una riga e restituisce null.
+	"puts": {Fn: func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			fmt.Println(arg.Inspect())
+		}
+		return NULL
+	}},
+
+	// first restituisce il primo elemento di un array, o null se è vuoto.
+	"first": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+		}
+		if len(arr.Elements) > 0 {
+			return arr.Elements[0]
+		}
+		return NULL
+	}},
+
+	// last restituisce l'ultimo elemento di un array, o null se è vuoto.
+	"last": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+		}
+		length := len(arr.Elements)
+		if length > 0 {
+			return arr.Elements[length-1]
+		}
+		return NULL
+	}},
+
+	// rest restituisce un nuovo array con tutti gli elementi tranne il primo, o null se è vuoto.
+	"rest": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+		}
+		length := len(arr.Elements)
+		if length > 0 {
+			newElements := make([]object.Object, length-1)
+			copy(newElements, arr.Elements[1:length])
+			return &object.Array{Elements: newElements}
+		}
+		return NULL
+	}},
+
+	// push restituisce un nuovo array con l'elemento aggiunto in coda; l'originale non viene modificato.
+	"push": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+		}
+		length := len(arr.Elements)
+		newElements := make([]object.Object, length+1)
+		copy(newElements, arr.Elements)
+		newElements[length] = args[1]
+		return &object.Array{Elements: newElements}
+	}},
+
+	// type restituisce il nome del tipo dell'argomento, es. "INTEGER".
+	"type": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return &object.String{Value: string(args[0].Type())}
+	}},
+
+	// int converte un FLOAT (troncando verso lo zero) o una STRING in INTEGER.
+	"int": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *object.Integer, *object.BigInteger:
+			return arg
+		case *object.Float:
+			if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+				return newError("cannot convert %s to INTEGER", arg.Inspect())
+			}
+			value, _ := big.NewFloat(arg.Value).Int(nil)
+			return object.IntegerFromBig(value)
+		case *object.String:
+			value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+			if !ok {
+				return newError("cannot convert %q to INTEGER", arg.Value)
+			}
+			return object.IntegerFromBig(value)
+		default:
+			return newError("argument to `int` not supported, got %s", args[0].Type())
+		}
+	}},
+
+	// float converte un INTEGER o una STRING in FLOAT.
+	"float": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *object.Float:
+			return arg
+		case *object.Integer, *object.BigInteger:
+			return &object.Float{Value: toFloat(arg)}
+		case *object.String:
+			value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+			if err != nil {
+				return newError("cannot convert %q to FLOAT", arg.Value)
+			}
+			return &object.Float{Value: value}
+		default:
+			return newError("argument to `float` not supported, got %s", args[0].Type())
+		}
+	}},
+
+	// error crea un valore di errore con un messaggio e, facoltativamente, dei dati.
+	// A differenza degli errori dell'interprete non interrompe l'esecuzione.
+	"error": {Fn: func(args ...object.Object) object.Object {
+		if len(args) < 1 || len(args) > 2 {
+			return newError("wrong number of arguments. got=%d, want=1..2", len(args))
+		}
+		message, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `error` must be STRING, got %s", args[0].Type())
+		}
+		var data object.Object = NULL
+		if len(args) == 2 {
+			data = args[1]
+		}
+		return &object.ErrorValue{Message: message.Value, Data: data}
+	}},
+
+	// is_error dice se l'argomento è un valore di errore creato con `error`.
+	"is_error": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return nativeBoolToBooleanObject(args[0].Type() == object.ERROR_VALUE_OBJ)
+	}},
+
+	// unwrap restituisce l'argomento se non è un valore di errore; altrimenti
+	// trasforma il valore di errore in un errore dell'interprete.
+	"unwrap": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		if errorValue, ok := args[0].(*object.ErrorValue); ok {
+			return newError("unwrap of error value: %s", errorValue.Message)
+		}
+		return args[0]
+	}},
+}
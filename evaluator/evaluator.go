@@ -3,69 +3,208 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey-interpreter/ast"
 	"monkey-interpreter/object"
+	"monkey-interpreter/token"
+	"strings"
 )
 
 // Oggetti singleton riutilizzati per efficienza.
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 /*
 Eval è il cuore dell'interprete. Percorre l'albero sintattico (AST) e, a seconda
 del tipo di nodo, delega il lavoro a funzioni specifiche.
+
+Eval non limita i passi né il tempo di esecuzione, ma solo la profondità delle
+chiamate (DefaultMaxDepth), che le chiamate in coda non consumano: per codice
+non fidato si usano EvalContext o un Interpreter configurato con dei Limits.
 */
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return NewInterpreter(Limits{}).eval(node, env)
+}
+
+/*
+eval valuta un nodo contando un passo del budget dell'interprete.
+
+Se il risultato è un errore che non sa ancora dove è nato, gli assegniamo la
+posizione del nodo corrente: essendo il nodo più interno a vederlo passare, è
+il punto più preciso che possiamo indicare all'utente.
+*/
+func (in *Interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	// Un AST incompleto (es. da un parsing fallito) può contenere nodi mancanti:
+	// li segnaliamo come errore invece di dereferenziare un puntatore nil.
+	if ast.IsNil(node) {
+		return newError("invalid program: missing %s", ast.KindOf(node))
+	}
+	if err := in.step(); err != nil {
+		return err
+	}
+
+	return locate(in.evalNode(node, env), node)
+}
+
+/*
+evalTail è come eval, ma per un nodo in posizione di coda nel corpo di una
+funzione: il suo valore sarà il risultato della funzione. Se il nodo è una
+chiamata a una funzione definita dall'utente, invece di eseguirla restituisce un
+*tailCall, che applyFunction esegue al posto della funzione corrente senza far
+crescere lo stack di Go (vedi callFunction).
+
+Sono in posizione di coda l'ultima istruzione del corpo, i rami di un if in
+posizione di coda e l'operando di un return (vedi Interpreter.tailCalls).
+*/
+func (in *Interpreter) evalTail(node ast.Node, env *object.Environment) object.Object {
+	if ast.IsNil(node) {
+		return newError("invalid program: missing %s", ast.KindOf(node))
+	}
+	if err := in.step(); err != nil {
+		return err
+	}
+
+	var result object.Object
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		result = in.evalTailBlock(node, env)
+	case *ast.ExpressionStatement:
+		result = in.evalTail(node.Expression, env)
+	case *ast.IfExpression:
+		result = in.evalIfExpression(node, env, true)
+	case *ast.CallExpression:
+		result = in.evalCallExpression(node, env, true)
+	default:
+		result = in.evalNode(node, env)
+	}
+	return locate(result, node)
+}
+
+// locate assegna a un errore che non sa ancora dove è nato la posizione di node.
+func locate(result object.Object, node ast.Node) object.Object {
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos, err.End = errorSpan(node)
+	}
+	return result
+}
+
+// errorSpan sceglie la parte più significativa di un nodo per un errore:
+// per gli operatori binari e le assegnazioni è l'operatore stesso, negli altri
+// casi l'intero nodo.
+func errorSpan(node ast.Node) (token.Position, token.Position) {
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		return node.Token.Pos, node.Token.End
+	case *ast.LogicalExpression:
+		return node.Token.Pos, node.Token.End
+	case *ast.AssignExpression:
+		return node.Token.Pos, node.Token.End
+	}
+	return node.Pos(), node.End()
+}
+
+// evalNode contiene lo smistamento vero e proprio per tipo di nodo.
+func (in *Interpreter) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Istruzioni
 	case *ast.Program:
-		return evalProgram(node, env)
+		return in.evalProgram(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return in.evalBlockStatement(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return in.eval(node.Expression, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		var val object.Object
+		if in.tailCalls {
+			val = in.evalTail(node.ReturnValue, env)
+		} else {
+			val = in.eval(node.ReturnValue, env)
+		}
+		if isUnwinding(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		if node.Name == nil {
+			return newError("invalid program: let statement without a name")
+		}
+		val := in.eval(node.Value, env)
+		if isUnwinding(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if err := in.alloc(bindingSize); err != nil {
+			return err
+		}
+		declare(env, node.Name, val)
 		return val
+	case *ast.WhileStatement:
+		return in.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return in.evalForStatement(node, env)
+	case *ast.ThrowStatement:
+		val := in.eval(node.Value, env)
+		if isUnwinding(val) {
+			return val
+		}
+		return &object.Error{Message: val.Inspect(), Value: val}
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 
 	// Espressioni
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			return in.track(object.IntegerFromBig(node.Big))
+		}
+		return in.track(object.NewInteger(node.Value))
+	case *ast.FloatLiteral:
+		return in.track(&object.Float{Value: node.Value})
+	case *ast.StringLiteral:
+		return in.track(&object.String{Value: node.Value})
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.Identifier:
+		if node.Binding != nil {
+			return evalBinding(node, env)
+		}
 		return evalIdentifier(node, env)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := in.eval(node.Right, env)
+		if isUnwinding(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return in.track(evalPrefixExpression(node.Operator, right))
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := in.eval(node.Left, env)
+		if isUnwinding(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := in.eval(node.Right, env)
+		if isUnwinding(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return in.infix(node.Operator, left, right)
+	case *ast.PostfixExpression:
+		left := in.eval(node.Left, env)
+		if isUnwinding(left) {
+			return left
+		}
+		return evalPostfixExpression(node.Operator, left)
+	case *ast.LogicalExpression:
+		return in.evalLogicalExpression(node, env)
+	case *ast.AssignExpression:
+		return in.evalAssignExpression(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return in.evalIfExpression(node, env, false)
+	case *ast.TryExpression:
+		return in.evalTryExpression(node, env)
 
 	/*
 		Quando viene definita una funzione `fn`, creiamo un oggetto Funzione.
@@ -73,57 +212,267 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		per permettere le chiusure (closures).
 	*/
 	case *ast.FunctionLiteral:
-		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+		return in.track(&object.Function{Parameters: node.Parameters, Defaults: node.Defaults, Rest: node.Rest, Body: node.Body, Env: env, Name: node.Name, Scope: node.Scope})
 
 	/*
 		Quando viene chiamata una funzione, valutiamo prima la funzione stessa,
 		poi i suoi argomenti, e infine eseguiamo la chiamata vera e propria.
 	*/
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		return in.evalCallExpression(node, env, false)
+
+	case *ast.ArrayLiteral:
+		elements := in.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isUnwinding(elements[0]) {
+			return elements[0]
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		return in.track(&object.Array{Elements: elements})
+	case *ast.IndexExpression:
+		left := in.eval(node.Left, env)
+		if isUnwinding(left) {
+			return left
 		}
-		return applyFunction(function, args)
+		index := in.eval(node.Index, env)
+		if isUnwinding(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.HashLiteral:
+		return in.evalHashLiteral(node, env)
 	}
 
-	return nil
+	return newError("unknown node type: %T", node)
 }
 
+/*
+evalCallExpression valuta una chiamata: prima la funzione stessa, poi i suoi
+argomenti, e infine esegue la chiamata vera e propria. In posizione di coda
+(tail vero) una funzione definita dall'utente non viene eseguita subito: la
+chiamata viene restituita come *tailCall.
+*/
+func (in *Interpreter) evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	function := in.eval(node.Function, env)
+	if isUnwinding(function) {
+		return function
+	}
+	args := in.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isUnwinding(args[0]) {
+		return args[0]
+	}
+	if fn, ok := function.(*object.Function); ok && tail {
+		// Un errore di arità nasce mentre la funzione corrente è ancora attiva.
+		if err := checkArity(fn, args); err != nil {
+			return traceCall(err, fn, node)
+		}
+		return &tailCall{fn: fn, args: args, call: node}
+	}
+	return traceCall(in.applyFunction(function, args), function, node)
+}
+
+/*
+tailCall è una chiamata in posizione di coda non ancora eseguita. Non è un
+valore del linguaggio: esce solo dal corpo di una funzione, e callFunction la
+consuma subito.
+*/
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	call *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.call.String() }
+
 /*
 applyFunction orchestra l'esecuzione di una funzione:
-1. Controlla che l'oggetto sia effettivamente una funzione.
+1. Controlla che l'oggetto sia effettivamente una funzione (o una funzione nativa).
 2. Crea un nuovo ambiente (scope) per l'esecuzione.
 3. Valuta il corpo della funzione in questo nuovo ambiente.
 4. Gestisce il valore di ritorno.
 */
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
-	if !ok {
+func (in *Interpreter) applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if err := in.enterCall(); err != nil {
+			return err
+		}
+		defer in.exitCall()
+
+		// Nel corpo della funzione i return sono di nuovo in posizione di coda,
+		// anche se la chiamata avviene dentro un try del chiamante.
+		tailCalls := in.tailCalls
+		in.tailCalls = true
+		defer func() { in.tailCalls = tailCalls }()
+
+		return in.callFunction(function, args)
+	case *object.Builtin:
+		// Non sappiamo se il risultato è nuovo o già esistente (es. first):
+		// lo contiamo comunque, sbagliando per eccesso.
+		return in.track(function.Fn(args...))
+	default:
 		return newError("not a function: %s", fn.Type())
 	}
+}
 
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
+/*
+callFunction esegue il corpo di fn e, finché questo termina con una chiamata in
+coda, esegue la funzione chiamata al suo posto: è un trampolino, che tiene una
+catena di chiamate in coda (es. una ricorsione in coda) in uno spazio costante
+sullo stack di Go e senza consumare la profondità massima.
 
+Il frame della funzione sostituita scompare anche dalla traccia degli errori:
+resta solo quello dell'ultima chiamata in coda, oltre a quello della chiamata
+originale che aggiunge il chiamante.
+*/
+func (in *Interpreter) callFunction(fn *object.Function, args []object.Object) object.Object {
+	var call *ast.CallExpression
+	for {
+		result := in.evalFunctionBody(fn, args)
+		next, ok := result.(*tailCall)
+		if !ok {
+			if call != nil {
+				result = traceCall(locate(result, call), fn, call)
+			}
+			return result
+		}
+		fn, args, call = next.fn, next.args, next.call
+	}
+}
+
+// evalFunctionBody esegue il corpo di fn con gli argomenti args, restituendo il
+// suo risultato o la chiamata in coda con cui termina.
+func (in *Interpreter) evalFunctionBody(fn *object.Function, args []object.Object) object.Object {
+	extendedEnv, err := in.extendFunctionEnv(fn, args)
+	if err != nil {
+		return err
+	}
+	evaluated := in.evalTail(fn.Body, extendedEnv)
+	if isLoopSignal(evaluated) {
+		return newError("%s outside of a loop", evaluated.Inspect())
+	}
 	return unwrapReturnValue(evaluated)
 }
 
+/*
+traceCall aggiunge un frame alla traccia di un errore uscito da una funzione
+definita dall'utente. Non serve tenere uno stack globale: la traccia si
+costruisce da sola mentre l'errore risale le chiamate, ognuna delle quali
+aggiunge il proprio frame. Le funzioni built-in non aggiungono frame, perché
+la posizione dell'errore indica già la loro chiamata.
+*/
+func traceCall(result object.Object, fn object.Object, call *ast.CallExpression) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return result
+	}
+
+	name := function.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	err.Trace = append(err.Trace, object.Frame{Function: name, Pos: call.Pos()})
+	return err
+}
+
 /*
 extendFunctionEnv crea l'ambiente locale per l'esecuzione di una funzione.
 Collega questo nuovo ambiente a quello in cui la funzione è stata definita,
 e poi inserisce le variabili dei parametri.
+
+I parametri senza argomento prendono il loro valore predefinito, valutato
+nell'ambiente locale così da poter usare i parametri precedenti (es.
+fn(x, y = x * 2)). Gli argomenti in eccesso finiscono nel parametro variadico
+come array; se non c'è, o se mancano argomenti obbligatori, la chiamata
+fallisce con un errore.
 */
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func (in *Interpreter) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	if err := checkArity(fn, args); err != nil {
+		return nil, err
+	}
+
+	bindings := len(fn.Parameters)
+	if fn.Rest != nil {
+		bindings++
+	}
+	if err := in.alloc(environmentSize + int64(bindings)*bindingSize); err != nil {
+		return nil, err
+	}
+
+	env := newScopeEnvironment(fn.Env, fn.Scope)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			declare(env, param, args[paramIdx])
+			continue
+		}
+		val := in.eval(fn.Defaults[paramIdx], env)
+		if isError(val) {
+			return nil, val.(*object.Error)
+		}
+		declare(env, param, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		array := in.track(&object.Array{Elements: rest})
+		if err, ok := array.(*object.Error); ok {
+			return nil, err
+		}
+		declare(env, fn.Rest, array)
+	}
+
+	return env, nil
+}
+
+// checkArity verifica che args basti per i parametri obbligatori di fn e, se
+// fn non è variadica, che non ne avanzino.
+func checkArity(fn *object.Function, args []object.Object) *object.Error {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return newError("wrong number of arguments. got=%d, want=%s", len(args), arity(fn, required))
+	}
+	return nil
+}
+
+// newScopeEnvironment crea l'ambiente di una funzione o di un blocco catch:
+// ad array se il resolver ne ha calcolato gli slot, a mappa altrimenti.
+func newScopeEnvironment(outer *object.Environment, scope *ast.Scope) *object.Environment {
+	if scope != nil {
+		return object.NewFrameEnvironment(outer, len(scope.Names))
+	}
+	return object.NewEnclosedEnvironment(outer)
+}
+
+// declare lega name a val nell'ambiente corrente, nel suo slot se è stato risolto.
+func declare(env *object.Environment, name *ast.Identifier, val object.Object) {
+	if name.Binding != nil {
+		env.SetSlot(0, name.Binding.Slot, val)
+		return
+	}
+	env.Set(name.Value, val)
+}
+
+// arity descrive il numero di argomenti accettati da fn, es. "2", "1..3" o "1+".
+func arity(fn *object.Function, required int) string {
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("%d+", required)
+	case required == len(fn.Parameters):
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d..%d", required, len(fn.Parameters))
 	}
-	return env
 }
 
 /*
@@ -139,46 +488,161 @@ func unwrapReturnValue(obj object.Object) object.Object {
 
 // --- Funzioni di supporto per la valutazione---
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
+func (in *Interpreter) evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object = NULL
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = in.eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			// Il parser lo impedisce, ma un AST costruito a mano potrebbe contenerlo.
+			return newError("%s outside of a loop", result.Inspect())
 		}
 	}
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+func (in *Interpreter) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		result = in.eval(statement, env)
+		rt := result.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+			rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+			return result
 		}
 	}
 	return result
 }
 
+// evalTailBlock è evalBlockStatement per un blocco in posizione di coda: la sua
+// ultima istruzione è a sua volta in posizione di coda.
+func (in *Interpreter) evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if len(block.Statements) == 0 {
+		return NULL
+	}
+	last := len(block.Statements) - 1
+	for _, statement := range block.Statements[:last] {
+		result := in.eval(statement, env)
+		rt := result.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+			rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+			return result
+		}
+	}
+	return in.evalTail(block.Statements[last], env)
+}
+
+/*
+evalWhileStatement esegue il corpo finché la condizione è vera.
+I segnali BREAK e CONTINUE prodotti dal corpo vengono consumati qui, mentre
+errori e ReturnValue interrompono il ciclo e risalgono al chiamante.
+Un ciclo, come istruzione, produce sempre `null`.
+*/
+func (in *Interpreter) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := in.eval(ws.Condition, env)
+		if isUnwinding(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := in.eval(ws.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if isError(result) || isReturnValue(result) {
+			return result
+		}
+	}
+}
+
+/*
+evalForStatement esegue un ciclo in stile C. Il ciclo non introduce un nuovo scope:
+come per i blocchi di `if`, le variabili dichiarate in init restano visibili dopo il ciclo.
+*/
+func (in *Interpreter) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	if fs.Init != nil {
+		if init := in.eval(fs.Init, env); isUnwinding(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := in.eval(fs.Condition, env)
+			if isUnwinding(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		result := in.eval(fs.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if isError(result) || isReturnValue(result) {
+			return result
+		}
+
+		if fs.Update != nil {
+			if update := in.eval(fs.Update, env); isUnwinding(update) {
+				return update
+			}
+		}
+	}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
+	// Le variabili definite dall'utente hanno la precedenza sulle funzioni native.
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: " + node.Value)
+}
+
+/*
+evalBinding legge una variabile risolta dal resolver direttamente dal suo slot.
+Lo slot è vuoto se il `let` che la dichiara non è ancora stato eseguito: come in
+evalIdentifier, in quel caso si passa alla variabile omonima di uno scope
+esterno e, in mancanza, alle funzioni built-in.
+*/
+func evalBinding(node *ast.Identifier, env *object.Environment) object.Object {
+	if _, val := lookupBinding(node.Binding, env); val != nil {
+		return val
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// lookupBinding segue b e le variabili che nasconde (vedi ast.Binding.Shadowed) e
+// restituisce la prima che ha già un valore, con il valore; nil se nessuna ce l'ha.
+func lookupBinding(b *ast.Binding, env *object.Environment) (*ast.Binding, object.Object) {
+	for ; b != nil && b.Scope != ast.BuiltinBinding; b = b.Shadowed {
+		if val := env.Slot(b.Depth, b.Slot); val != nil {
+			return b, val
+		}
+	}
+	return nil, nil
+}
+
+func (in *Interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		evaluated := in.eval(e, env)
+		if isUnwinding(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -186,18 +650,213 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ:
+		return evalErrorValueIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+/*
+evalArrayIndexExpression restituisce l'elemento all'indice richiesto.
+Gli indici negativi contano a partire dalla fine (-1 è l'ultimo elemento),
+mentre un indice fuori dai limiti restituisce `null` invece di un errore.
+*/
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// Un BigInteger è sempre fuori dai limiti di qualunque array.
+		return NULL
+	}
+	idx := integer.Value
+	length := int64(len(arrayObject.Elements))
+
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return arrayObject.Elements[idx]
+}
+
+// evalHashIndexExpression cerca la chiave nella mappa; una chiave assente restituisce `null`.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
+
+// evalErrorValueIndexExpression dà accesso ai campi di un valore di errore:
+// err["message"] e err["data"]. Gli altri indici valgono `null`, come le
+// chiavi assenti di una mappa.
+func evalErrorValueIndexExpression(errorValue, index object.Object) object.Object {
+	ev := errorValue.(*object.ErrorValue)
+
+	field, ok := index.(*object.String)
+	if !ok {
+		return newError("error value field must be STRING, got %s", index.Type())
+	}
+
+	switch field.Value {
+	case "message":
+		return &object.String{Value: ev.Message}
+	case "data":
+		return ev.Data
+	default:
+		return NULL
+	}
+}
+
+// evalHashLiteral valuta le coppie nell'ordine del sorgente, verificando che ogni chiave sia Hashable.
+func (in *Interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := in.eval(pair.Key, env)
+		if isUnwinding(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := in.eval(pair.Value, env)
+		if isUnwinding(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return in.track(hash)
+}
+
+// evalIfExpression valuta il ramo scelto dalla condizione; se l'if è in
+// posizione di coda (tail vero), lo sono anche i suoi rami.
+func (in *Interpreter) evalIfExpression(ie *ast.IfExpression, env *object.Environment, tail bool) object.Object {
+	condition := in.eval(ie.Condition, env)
+	if isUnwinding(condition) {
 		return condition
 	}
+
+	var branch ast.Node
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		branch = ie.Consequence
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		branch = ie.Alternative
 	} else {
 		return NULL
 	}
+	if tail {
+		return in.evalTail(branch, env)
+	}
+	return in.eval(branch, env)
+}
+
+/*
+evalTryExpression esegue il blocco protetto e, se questo produce un errore del
+programma, il blocco catch in un nuovo ambiente in cui il nome tra parentesi è
+legato al valore catturato. Gli errori con un Kind diverso da RuntimeError
+(limiti superati, annullamento, guasti interni) appartengono all'host: non
+vengono catturati e saltano anche il blocco finally.
+
+Il blocco finally viene eseguito in tutti gli altri casi; se a sua volta produce
+un errore, un return o un break/continue, questo prende il posto del risultato.
+*/
+func (in *Interpreter) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	// Un return nel blocco protetto non è in posizione di coda: gli errori della
+	// chiamata vanno catturati qui. Lo stesso vale nel catch se c'è un finally,
+	// che va eseguito dopo la chiamata.
+	tailCalls := in.tailCalls
+	in.tailCalls = false
+	result := in.eval(te.Block, env)
+	in.tailCalls = tailCalls
+
+	err, failed := result.(*object.Error)
+	if failed && err.Kind != object.RuntimeError {
+		return result
+	}
+
+	if failed && te.Catch != nil {
+		catchEnv := newScopeEnvironment(env, te.CatchScope)
+		if te.Param != nil {
+			caught := err.Value
+			if caught == nil {
+				caught = in.track(errorToHash(err))
+			}
+			if allocErr := in.alloc(environmentSize + bindingSize); allocErr != nil {
+				return allocErr
+			}
+			declare(catchEnv, te.Param, caught)
+		}
+		in.tailCalls = tailCalls && te.Finally == nil
+		result = in.eval(te.Catch, catchEnv)
+		in.tailCalls = tailCalls
+	}
+
+	if te.Finally != nil {
+		final := in.eval(te.Finally, env)
+		if isError(final) || isReturnValue(final) || isLoopSignal(final) {
+			return final
+		}
+	}
+
+	return result
+}
+
+/*
+errorToHash espone un errore dell'interprete al codice Monkey come hash con i
+campi "message", "kind", "line" e "column" (e "file", se noto), es.
+e["message"] dentro un blocco catch.
+*/
+func errorToHash(err *object.Error) *object.Hash {
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "message"}, &object.String{Value: err.Message})
+	hash.Set(&object.String{Value: "kind"}, &object.String{Value: err.Kind.String()})
+	hash.Set(&object.String{Value: "line"}, object.NewInteger(int64(err.Pos.Line)))
+	hash.Set(&object.String{Value: "column"}, object.NewInteger(int64(err.Pos.Column)))
+	if err.Pos.Filename != "" {
+		hash.Set(&object.String{Value: "file"}, &object.String{Value: err.Pos.Filename})
+	}
+	return hash
+}
+
+/*
+evalPostfixExpression applica un operatore postfisso. L'unico è `?`: se l'operando
+è un valore di errore lo avvolge in un ReturnValue, che risale come un `return`
+fino alla funzione che lo contiene; altrimenti lascia passare il valore.
+*/
+func evalPostfixExpression(operator string, left object.Object) object.Object {
+	switch operator {
+	case "?":
+		if left.Type() == object.ERROR_VALUE_OBJ {
+			return &object.ReturnValue{Value: left}
+		}
+		return left
+	default:
+		return newError("unknown operator: %s%s", left.Type(), operator)
+	}
 }
 
 func isTruthy(obj object.Object) bool {
@@ -215,15 +874,183 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
+/*
+evalLogicalExpression valuta "&&" e "||" in modo cortocircuitato: l'operando destro
+viene valutato solo se quello sinistro non basta a decidere il risultato.
+Come in JavaScript, il risultato è l'operando che ha deciso, non un booleano forzato.
+*/
+func (in *Interpreter) evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := in.eval(node.Left, env)
+	if isUnwinding(left) {
+		return left
+	}
+
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return left
+		}
+	case "||":
+		if isTruthy(left) {
+			return left
+		}
+	default:
+		return newError("unknown operator: %s %s", left.Type(), node.Operator)
+	}
+
+	return in.eval(node.Right, env)
+}
+
+/*
+evalAssignExpression gestisce "x = v" e gli assegnamenti composti ("x += v" equivale
+a "x = x + v"). Il risultato dell'espressione è il valore assegnato.
+  - Per un identificatore, la variabile deve essere già dichiarata con `let` in questo
+    scope o in uno esterno: viene aggiornata nello scope in cui è stata dichiarata.
+  - Per un'espressione di indice, l'array o la mappa vengono modificati sul posto.
+*/
+func (in *Interpreter) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if target.Binding != nil {
+			return in.evalSlotAssignment(node, target.Binding, env)
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			var ok bool
+			if current, ok = env.Get(target.Value); !ok {
+				return newError("assignment to undeclared identifier: %s", target.Value)
+			}
+		}
+
+		val := in.evalAssignedValue(node, current, env)
+		if isUnwinding(val) {
+			return val
+		}
+
+		if !env.Assign(target.Value, val) {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := in.eval(target.Left, env)
+		if isUnwinding(left) {
+			return left
+		}
+		index := in.eval(target.Index, env)
+		if isUnwinding(index) {
+			return index
+		}
+		return in.evalIndexAssignment(node, left, index, env)
+
+	default:
+		return newError("invalid assignment target: %s", node.Target)
+	}
+}
+
+// evalSlotAssignment assegna una variabile risolta dal resolver, con gli stessi
+// errori di evalAssignExpression se il suo `let` non è ancora stato eseguito.
+func (in *Interpreter) evalSlotAssignment(node *ast.AssignExpression, b *ast.Binding, env *object.Environment) object.Object {
+	name := node.Target.(*ast.Identifier).Value
+	_, current := lookupBinding(b, env)
+	if current == nil && node.Operator != "=" {
+		return newError("assignment to undeclared identifier: %s", name)
+	}
+
+	val := in.evalAssignedValue(node, current, env)
+	if isUnwinding(val) {
+		return val
+	}
+
+	// Il lato destro può aver eseguito un `let` (es. in un blocco if): la
+	// variabile da aggiornare va cercata di nuovo.
+	target, _ := lookupBinding(b, env)
+	if target == nil {
+		return newError("assignment to undeclared identifier: %s", name)
+	}
+	env.SetSlot(target.Depth, target.Slot, val)
+	return val
+}
+
+// evalAssignedValue valuta il lato destro di un assegnamento; per gli operatori composti
+// lo combina con il valore corrente del bersaglio (es. "+=" applica "+").
+func (in *Interpreter) evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := in.eval(node.Value, env)
+	if isUnwinding(val) || node.Operator == "=" {
+		return val
+	}
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return in.infix(operator, current, val)
+}
+
+// evalIndexAssignment assegna un elemento di un array (l'indice deve esistere; i negativi
+// contano dalla fine) o una chiave di una mappa (che viene aggiunta se assente).
+func (in *Interpreter) evalIndexAssignment(node *ast.AssignExpression, left, index object.Object, env *object.Environment) object.Object {
+	switch container := left.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		idx := integer.Value
+		length := int64(len(container.Elements))
+		if idx < 0 {
+			idx += length
+		}
+		if idx < 0 || idx >= length {
+			return newError("index out of range: %d (length %d)", integer.Value, length)
+		}
+
+		val := in.evalAssignedValue(node, container.Elements[idx], env)
+		if isUnwinding(val) {
+			return val
+		}
+		container.Elements[idx] = val
+		return val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		var current object.Object = NULL
+		existing, found := container.Get(key)
+		if found {
+			current = existing
+		} else if err := in.alloc(hashPairSize); err != nil {
+			return err
+		}
+
+		val := in.evalAssignedValue(node, current, env)
+		if isUnwinding(val) {
+			return val
+		}
+		container.Set(key, val)
+		return val
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// Almeno uno dei due operandi è FLOAT: l'intero viene promosso a float.
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -235,22 +1062,289 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+/*
+evalIntegerInfixExpression gestisce le operazioni tra interi. Se entrambi gli operandi
+sono int64 si usa l'aritmetica nativa; se il risultato andrebbe in overflow, o se uno dei
+due operandi è già un BigInteger, il calcolo viene ripetuto con math/big.
+*/
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	if (operator == "/" || operator == "%") && isZeroInteger(right) {
+		return newError("division by zero")
+	}
+
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
+	switch operator {
+	case "+":
+		sum := leftVal + rightVal
+		if (leftVal^sum)&(rightVal^sum) < 0 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.NewInteger(sum)
+	case "-":
+		diff := leftVal - rightVal
+		if (leftVal^rightVal)&(leftVal^diff) < 0 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.NewInteger(diff)
+	case "*":
+		if leftVal == 0 || rightVal == 0 {
+			return object.NewInteger(0)
+		}
+		product := leftVal * rightVal
+		if product/rightVal != leftVal || (leftVal == -1 && rightVal == math.MinInt64) ||
+			(rightVal == -1 && leftVal == math.MinInt64) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.NewInteger(product)
+	case "/":
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return object.NewInteger(leftVal / rightVal)
+	case "%":
+		return object.NewInteger(leftVal % rightVal)
+	case "**":
+		if rightVal < 0 {
+			// Un esponente negativo dà un risultato frazionario, come in Python.
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
+	case "&":
+		return object.NewInteger(leftVal & rightVal)
+	case "|":
+		return object.NewInteger(leftVal | rightVal)
+	case "^":
+		return object.NewInteger(leftVal ^ rightVal)
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if rightVal < 63 && (leftVal<<rightVal)>>rightVal == leftVal {
+			return object.NewInteger(leftVal << rightVal)
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return object.NewInteger(leftVal >> uint64(rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+/*
+MaxIntegerBits è la dimensione massima, in bit, di un intero prodotto da **, << o *.
+Vale anche senza una quota di memoria: oltre questa soglia il calcolo
+richiederebbe gigabyte (o fallirebbe dentro math/big), quindi lo rifiutiamo con
+un errore prima di iniziarlo.
+*/
+const MaxIntegerBits = 1 << 26
+
+// evalBigIntegerInfixExpression esegue l'operazione con precisione arbitraria e
+// riporta il risultato a *object.Integer quando torna a essere rappresentabile.
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	switch operator {
+	case "+":
+		return object.IntegerFromBig(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.IntegerFromBig(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		if bits := int64(leftVal.BitLen()) + int64(rightVal.BitLen()); bits > MaxIntegerBits {
+			return newError("integer too large: result of * would exceed %d bits", MaxIntegerBits)
+		}
+		return object.IntegerFromBig(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		return object.IntegerFromBig(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		return object.IntegerFromBig(new(big.Int).Rem(leftVal, rightVal))
+	case "**":
+		if rightVal.Sign() < 0 {
+			return &object.Float{Value: math.Pow(toFloat(left), toFloat(right))}
+		}
+		// 0, 1 e -1 restano piccoli con qualunque esponente; per gli altri valori il
+		// risultato ha almeno (bit della base - 1) bit per ogni unità dell'esponente.
+		if bits := int64(new(big.Int).Abs(leftVal).BitLen()) - 1; bits > 0 {
+			if !rightVal.IsInt64() || rightVal.Int64() > MaxIntegerBits/bits {
+				return newError("integer too large: result of ** would exceed %d bits", MaxIntegerBits)
+			}
+		} else if !rightVal.IsInt64() {
+			return newError("exponent too large: %s", rightVal)
+		}
+		return object.IntegerFromBig(new(big.Int).Exp(leftVal, rightVal, nil))
+	case "&":
+		return object.IntegerFromBig(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return object.IntegerFromBig(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return object.IntegerFromBig(new(big.Int).Xor(leftVal, rightVal))
+	case "<<", ">>":
+		return evalBigIntegerShift(operator, leftVal, rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalBigIntegerShift esegue gli shift con precisione arbitraria. Uno shift a destra
+// di una quantità enorme restituisce 0 o -1 a seconda del segno, come per gli int64.
+func evalBigIntegerShift(operator string, value, count *big.Int) object.Object {
+	if count.Sign() < 0 {
+		return newError("negative shift count: %s", count)
+	}
+	if !count.IsInt64() {
+		if operator == "<<" {
+			return newError("shift count too large: %s", count)
+		}
+		if value.Sign() < 0 {
+			return object.NewInteger(-1)
+		}
+		return object.NewInteger(0)
+	}
+	if operator == "<<" {
+		if value.Sign() == 0 {
+			return object.NewInteger(0)
+		}
+		if bits := int64(value.BitLen()); count.Int64() > MaxIntegerBits-bits {
+			return newError("integer too large: result of << would exceed %d bits", MaxIntegerBits)
+		}
+		return object.IntegerFromBig(new(big.Int).Lsh(value, uint(count.Int64())))
+	}
+	return object.IntegerFromBig(new(big.Int).Rsh(value, uint(count.Int64())))
+}
+
+// isZeroInteger verifica se un intero, in qualunque rappresentazione, vale zero.
+func isZeroInteger(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value == 0
+	case *object.BigInteger:
+		return obj.Value.Sign() == 0
+	default:
+		return false
+	}
+}
+
+// toBigInt converte un intero, in qualunque rappresentazione, in *big.Int.
+// Il valore restituito non va modificato: può essere condiviso con l'oggetto.
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+// evalFloatInfixExpression gestisce le operazioni tra numeri quando almeno uno è FLOAT.
+// La divisione per zero segue IEEE 754 e produce Inf o NaN.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// isNumber verifica se l'oggetto è un valore numerico (INTEGER o FLOAT).
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInteger, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+// toFloat converte un oggetto numerico in float64. Va chiamata solo dopo isNumber.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+// evalStringInfixExpression gestisce la concatenazione e i confronti (lessicografici) tra stringhe.
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -272,11 +1366,31 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.IntegerFromBig(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return object.NewInteger(-right.Value)
+	case *object.BigInteger:
+		return object.IntegerFromBig(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+}
+
+// evalTildePrefixOperatorExpression calcola il complemento bit a bit di un intero (~x == -x - 1).
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return object.NewInteger(^right.Value)
+	case *object.BigInteger:
+		return object.IntegerFromBig(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -290,6 +1404,26 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+func isReturnValue(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.RETURN_VALUE_OBJ
+}
+
+// isLoopSignal verifica se l'oggetto è uno dei segnali BREAK o CONTINUE.
+func isLoopSignal(obj object.Object) bool {
+	return obj == BREAK || obj == CONTINUE
+}
+
+/*
+isUnwinding verifica se l'oggetto deve interrompere la valutazione dell'espressione
+che lo contiene e risalire: un errore, un ReturnValue prodotto dentro
+un'espressione dall'operatore `?`, oppure un segnale BREAK o CONTINUE uscito da
+un blocco (es. `puts(if (x) { break })`), che deve raggiungere il suo ciclo
+invece di diventare un valore.
+*/
+func isUnwinding(obj object.Object) bool {
+	return isError(obj) || isReturnValue(obj) || isLoopSignal(obj)
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
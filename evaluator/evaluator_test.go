@@ -2,6 +2,7 @@
 package evaluator
 
 import (
+	"monkey-interpreter/ast"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/object"
 	"monkey-interpreter/parser"
@@ -36,6 +37,19 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 >> 70", 0},
+		{"1 + 2 << 1", 6},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -71,6 +85,18 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"2 >= 3", false},
+		{"3 >= 3", true},
+		{"1.5 <= 1.5", true},
+		{`"a" >= "b"`, false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -200,6 +226,58 @@ return 1;
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"-1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"10 % (5 - 5)",
+			"division by zero",
+		},
+		{
+			"9223372036854775808 / 0",
+			"division by zero",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"~true",
+			"unknown operator: ~BOOLEAN",
+		},
+		{
+			"true && 5 + true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`5(1)`,
+			"not a function: INTEGER",
+		},
+		{
+			`5[0]`,
+			"index operator not supported: INTEGER",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{[1]: 2}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`"Hello" + 1`,
+			"type mismatch: STRING + INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -230,3 +308,1091 @@ func TestLetStatements(t *testing.T) {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
 }
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`"Hello" + " " + "World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"b" < "abc"`, false},
+		{`let s = "x"; s + "y" == "xy"`, true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+
+	if result.Inspect() != "[1, 4, 6]" {
+		t.Errorf("array.Inspect() wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
+		{"[][0]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if result.Inspect() != `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}` {
+		t.Errorf("hash.Inspect() wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("\u{e8}")`, 1},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([])`, "wrong number of arguments. got=1, want=2"},
+		{`puts("hello")`, nil},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(len)`, "BUILTIN"},
+		{`let len = fn(x) { 42 }; len("abc")`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch result := evaluated.(type) {
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+				}
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, result.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1 + 2.5", 3.5},
+		{"2.5 + 1", 3.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 0.25", 9.75},
+		{"float(3)", 3},
+		{"float(\"2.5\")", 2.5},
+		{"let x = .5; x * x", 0.25},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.5 > 1", true},
+		{"2 < 1.5", false},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatConversions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(\"42\")", 42},
+		{"int(7)", 7},
+		{"int(1.0 / 0)", "cannot convert Inf to INTEGER"},
+		{"int(0.0 / 0)", "cannot convert NaN to INTEGER"},
+		{"int(\"abc\")", "cannot convert \"abc\" to INTEGER"},
+		{"float(true)", "argument to `float` not supported, got BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"3.25", "3.25"},
+		{"1e21", "1e+21"},
+		{"1.0 / 0", "Inf"},
+		{"-1.0 / 0", "-Inf"},
+		{"0.0 / 0", "NaN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 * 0", "0"},
+		{"let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+		{"int(\"100000000000000000000\")", "100000000000000000000"},
+		{"int(1e20)", "100000000000000000000"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != object.INTEGER_OBJ {
+			t.Errorf("%q: object is not INTEGER. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong value. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBigIntegerDemotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775808 - 1", 9223372036854775807},
+		{"(9223372036854775807 + 10) - 20", 9223372036854775797},
+		{"-9223372036854775808", -9223372036854775808},
+		{"100000000000000000000 / 100000000000000000000", 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBigIntegerComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775808 > 9223372036854775807", true},
+		{"9223372036854775808 < 1", false},
+		{"9223372036854775807 + 1 == 9223372036854775808", true},
+		{"9223372036854775808 - 1 == 9223372036854775807", true},
+		{"9223372036854775808 != 9223372036854775808", false},
+		{"9223372036854775808 > 1.5", true},
+		{"{9223372036854775808: true}[9223372036854775807 + 1]", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+		{"let f = fn() { 1 / 0 }; false && f()", false},
+		{"null_ || 5", "identifier not found: null_"},
+		{"0 || 5", 0},
+		{"if (false) { 1 } || 5", 5},
+		{"1 && 2", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPowerAndShiftPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** 64", "18446744073709551616"},
+		{"1 << 64", "18446744073709551616"},
+		{"(1 << 64) >> 63", "2"},
+		{"(1 << 64) % 10", "6"},
+		{"(1 << 64) & 255", "0"},
+		{"~(1 << 64)", "-18446744073709551617"},
+		{"2 ** -1", "0.5"},
+		{"2.0 ** 0.5 > 1.41", "true"},
+		{"7.5 % 2", "1.5"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong value. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIntegerSizeCap(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** 4611686018427387904", "integer too large: result of ** would exceed 67108864 bits"},
+		{"(-3) ** 100000000", "integer too large: result of ** would exceed 67108864 bits"},
+		{"1 << 4611686018427387904", "integer too large: result of << would exceed 67108864 bits"},
+		{"(1 << 67108800) << 100", "integer too large: result of << would exceed 67108864 bits"},
+		{"let x = 1 << 40000000; x * x", "integer too large: result of * would exceed 67108864 bits"},
+		{"2 ** 100000000000000000000", "integer too large: result of ** would exceed 67108864 bits"},
+		{"10 ** 100000000000000000000", "integer too large: result of ** would exceed 67108864 bits"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+
+	// I valori che restano piccoli non hanno limiti sull'esponente o sullo shift.
+	allowed := []struct {
+		input    string
+		expected string
+	}{
+		{"1 ** 4611686018427387904", "1"},
+		{"(-1) ** 4611686018427387905", "-1"},
+		{"0 << 4611686018427387904", "0"},
+		{"(2 ** 100000) >> 99999", "2"},
+	}
+	for _, tt := range allowed {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%q: wrong value. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; } i", 5},
+		{"let i = 0; while (false) { let i = 1; } i", 0},
+		{"let i = 0; while (true) { if (i == 3) { break; } let i = i + 1; } i", 3},
+		{"let i = 0; let s = 0; while (i < 5) { let i = i + 1; if (i % 2 == 0) { continue; } let s = s + i; } s", 9},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i > 2) { return i * 10; } } }; f()", 30},
+		{"while (false) { 1 }", nil},
+		{"while (1 + true) { 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (true) { 1 + true }", "type mismatch: INTEGER + BOOLEAN"},
+		// break e continue dentro un'espressione raggiungono comunque il ciclo.
+		{"let f = fn() { let i = 0; while (true) { i += 1; len([if (i > 2) { break }]) } 7 }; f()", 7},
+		{"let i = 0; let s = 0; while (i < 5) { i += 1; s = s + if (i % 2 == 0) { continue } else { i } }; s", 9},
+		{"let i = 0; while (true) { i += 1; let h = {\"k\": if (i == 4) { break } else { i }} }; i", 4},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let s = 0; for (let i = 1; i <= 4; let i = i + 1) { let s = s + i; } s", 10},
+		{"let s = 0; for (let i = 0; i < 10; let i = i + 1) { if (i == 4) { break; } let s = s + i; } s", 6},
+		{"let s = 0; for (let i = 0; i < 5; let i = i + 1) { if (i == 2) { continue; } let s = s + i; } s", 8},
+		{"let n = 0; for (;;) { let n = n + 1; if (n == 7) { break; } } n", 7},
+		{"for (let i = 0; i < 3; let i = i + 1) { } i", 3},
+		{"let s = 0; for (let i = 0; i < 3; let i = i + 1) { for (let j = 0; j < 3; let j = j + 1) { if (j == 1) { break; } let s = s + 1; } } s", 3},
+		{"for (let i = 0; i < 3; let i = i + 1) { }", nil},
+		{"for (let i = 0; i < 3; let i = i + true) { }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func testLoopResult(t *testing.T, input string, expected interface{}) {
+	t.Helper()
+	evaluated := testEval(input)
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case nil:
+		testNullObject(t, evaluated)
+	case string:
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			return
+		}
+		if errObj.Message != expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x = 5", 5},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		{"let x = 10; x %= 4; x", 2},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		{"let s = \"a\"; s += \"b\"; s", "ab"},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", 3},
+		{"let n = 0; let inc = fn() { n = n + 1; }; inc(); inc(); n", 2},
+		{"let x = 1; let f = fn() { let x = 100; x = 5; }; f(); x", 1},
+		{"let s = 0; for (let i = 0; i < 5; i = i + 1) { s += i; } s", 10},
+		{"let s = 0; let i = 10; while (i > 0) { s += i; i -= 3; } s", 22},
+		{"x = 5", "assignment to undeclared identifier: x"},
+		{"x += 5", "assignment to undeclared identifier: x"},
+		{"len += 1", "assignment to undeclared identifier: len"},
+		{"let f = fn() { y *= 2; let y = 1 }; f()", "assignment to undeclared identifier: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testAssignmentResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3]; a[0] = 10; a[0]", 10},
+		{"let a = [1, 2, 3]; a[-1] = 30; a[2]", 30},
+		{"let a = [1, 2, 3]; a[1] += 5; a[1]", 7},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 9; a[0]", 9},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 8; m[1][0]", 8},
+		{`let h = {"k": 1}; h["k"] += 2; h["k"]`, 3},
+		{`let h = {}; h["new"] = 4; h["new"]`, 4},
+		{`let h = {}; h[1] = 2; len(h)`, 1},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 (length 1)"},
+		{"let a = [1]; a[-2] = 2", "index out of range: -2 (length 1)"},
+		{`let a = [1]; a["x"] = 2`, "array index must be INTEGER, got STRING"},
+		{`let h = {}; h[[1]] = 2`, "unusable as hash key: ARRAY"},
+		{`let h = {}; h["missing"] += 1`, "type mismatch: NULL + INTEGER"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		testAssignmentResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestSelfReferencingCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{`let h = {}; h["x"] = h; h`, `{"x": {...}}`},
+		{`let a = [1, 2]; let h = {"a": a}; a[1] = h; a`, `[1, {"a": [...]}]`},
+		{"let a = [1]; let b = [a, a]; b", "[[1], [1]]"},
+		{"let a = [1]; a[0] = a; puts(a); len(a)", "1"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%q: want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func testAssignmentResult(t *testing.T, input string, expected interface{}) {
+	t.Helper()
+	evaluated := testEval(input)
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case string:
+		switch result := evaluated.(type) {
+		case *object.Error:
+			if result.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+			}
+		case *object.String:
+			if result.Value != expected {
+				t.Errorf("wrong string. expected=%q, got=%q", expected, result.Value)
+			}
+		default:
+			t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `// commento di riga
+let x = 5; /* commento /* annidato */ a blocco */
+x / 5 // divisione, non un commento`
+
+	testIntegerObject(t, testEval(input), 1)
+}
+
+func TestTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { foo } catch (e) { 2 }", 2},
+		{"try { throw 5 } catch (e) { e * 2 }", 10},
+		{"try { throw 5 } catch { 7 }", 7},
+		{`try { throw {"code": 3} } catch (e) { e["code"] }`, 3},
+		{"let f = fn() { throw 4 }; try { f() } catch (e) { e }", 4},
+		{"let x = 0; try { throw 1 } catch (e) { x = 1 } finally { x = x + 10 }; x", 11},
+		{"let x = 0; try { 1 } finally { x = 5 }; x", 5},
+		{"let x = 0; try { throw 1 } finally { x = 5 }", "1"},
+		{"let f = fn() { try { return 1 } finally { 2 } }; f()", 1},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"try { throw 1 } catch (e) { throw e + 1 }", "2"},
+		{"let i = 0; while (true) { try { break } finally { i = i + 1 } }; i", 1},
+		{"let e = 1; try { throw 2 } catch (e) { e }; e", 1},
+		{"try { throw 1 } catch (e) { let y = 2 }; y", "identifier not found: y"},
+		{"throw \"boom\"", "boom"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestCaughtErrorFields(t *testing.T) {
+	input := `try {
+  let x = 1;
+  x + true
+} catch (e) {
+  [e["message"], e["kind"], e["line"], e["column"]]
+}`
+
+	evaluated := testEval(input)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := `["type mismatch: INTEGER + BOOLEAN", "runtime error", 3, 5]`
+	if array.Inspect() != expected {
+		t.Errorf("wrong fields. expected=%s, got=%s", expected, array.Inspect())
+	}
+}
+
+func TestThrowRecordsPosition(t *testing.T) {
+	evaluated := testEval("let f = fn() {\n  throw \"boom\"\n};\nf()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "ERROR: 2:3: boom\nTraceback (most recent call last):\n  at 4:1, in f"
+	if errObj.Inspect() != expected {
+		t.Errorf("wrong Inspect. expected=%q, got=%q", expected, errObj.Inspect())
+	}
+}
+
+func TestErrorValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`error("boom")`, `error("boom")`},
+		{`error("boom", [1, 2])`, `error("boom", [1, 2])`},
+		{`is_error(error("boom"))`, true},
+		{`is_error(5)`, false},
+		{`error("boom", 7)["data"]`, 7},
+		{`error("boom")["message"]`, "boom"},
+		{`error("boom")["data"]`, nil},
+		{`unwrap(5)`, 5},
+		{`error(1)`, "argument to `error` must be STRING, got INTEGER"},
+		{`error()`, "wrong number of arguments. got=0, want=1..2"},
+		{`unwrap(error("boom"))`, "unwrap of error value: boom"},
+		{`try { unwrap(error("boom")) } catch (e) { e["message"] }`, "unwrap of error value: boom"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			var got string
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				got = obj.Message
+			case *object.String:
+				got = obj.Value
+			default:
+				got = obj.Inspect()
+			}
+			if got != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestPropagateOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { 5? + 1 }; f()", "6"},
+		{`let f = fn() { error("boom")? + 1 }; f()`, `error("boom")`},
+		{`let f = fn() { let x = error("boom")?; 99 }; f()`, `error("boom")`},
+		{`let f = fn() { [1, error("boom")?, 3] }; f()`, `error("boom")`},
+		{`let f = fn() { len(error("boom")?) }; f()`, `error("boom")`},
+		{`let f = fn() { return error("boom")?; }; f()`, `error("boom")`},
+		{`let f = fn() { if (error("boom")?) { 1 } else { 2 } }; f()`, `error("boom")`},
+		{`let f = fn() { while (true) { error("boom")? } }; f()`, `error("boom")`},
+		{`let inner = fn() { error("boom") };
+		  let outer = fn() { let x = inner()?; x + 1 };
+		  let g = fn() { outer(); 42 };
+		  g()`, "42"},
+		{`let f = fn(s) { if (s == "") { return error("empty") } s }; f("ok")?`, "ok"},
+		{`error("top")?; 5`, `error("top")`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedPos     string
+		expectedInspect string
+	}{
+		{"5 + true;", "1:3", "ERROR: 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1;\n  foobar", "2:3", "ERROR: 2:3: identifier not found: foobar"},
+		{"let f = fn() {\n  -true\n};\nf()", "2:3", "ERROR: 2:3: unknown operator: -BOOLEAN\nTraceback (most recent call last):\n  at 4:1, in f"},
+		{"len(1, 2)", "1:1", "ERROR: 1:1: wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, got=%s", tt.input, tt.expectedPos, errObj.Pos)
+		}
+		if errObj.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong Inspect for %q. expected=%q, got=%q", tt.input, tt.expectedInspect, errObj.Inspect())
+		}
+	}
+}
+
+func TestErrorDiagnostic(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode string
+		expectedSpan string
+		expectedNote bool
+	}{
+		{"5 + true;", "R001", "1:3-1:4", false},
+		{"let x = 1;\n  foobar", "R001", "2:3-2:9", false},
+		{"let f = fn() { -true };\nf()", "R001", "1:16-1:21", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		d := errObj.Diagnostic()
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code for %q. expected=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if span := d.Span.Start.String() + "-" + d.Span.End.String(); span != tt.expectedSpan {
+			t.Errorf("wrong span for %q. expected=%s, got=%s", tt.input, tt.expectedSpan, span)
+		}
+		if hasNote := len(d.Notes) > 0; hasNote != tt.expectedNote {
+			t.Errorf("wrong notes for %q. expected traceback=%t, got=%q", tt.input, tt.expectedNote, d.Notes)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	// Nessuna chiamata è in posizione di coda: la traccia le mostra tutte.
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(x) { let r = inner(x); r };
+let apply = fn(f) { let r = f(1); r };
+apply(fn(y) { let r = outer(y); r });`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		function string
+		pos      string
+	}{
+		{"inner", "4:29"},
+		{"outer", "6:23"},
+		{"<anonymous>", "5:29"},
+		{"apply", "6:1"},
+	}
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong trace length. expected=%d, got=%d (%v)", len(expected), len(errObj.Trace), errObj.Trace)
+	}
+	for i, frame := range expected {
+		got := errObj.Trace[i]
+		if got.Function != frame.function || got.Pos.String() != frame.pos {
+			t.Errorf("wrong frame %d. expected=%s, in %s, got=%s", i, frame.pos, frame.function, got)
+		}
+	}
+
+	expectedInspect := `ERROR: 2:5: type mismatch: INTEGER + BOOLEAN
+Traceback (most recent call last):
+  at 6:1, in apply
+  at 5:29, in <anonymous>
+  at 6:23, in outer
+  at 4:29, in inner`
+	if errObj.Inspect() != expectedInspect {
+		t.Errorf("wrong Inspect.\nexpected=%s\ngot=%s", expectedInspect, errObj.Inspect())
+	}
+}
+
+func TestStackTraceTailCalls(t *testing.T) {
+	// Ogni chiamata in coda prende il posto del chiamante: restano l'ultima
+	// chiamata in coda e la chiamata originale.
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn(x) { inner(x) };
+let apply = fn(f) { f(1) };
+apply(fn(y) { outer(y) });`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expectedInspect := `ERROR: 2:5: type mismatch: INTEGER + BOOLEAN
+Traceback (most recent call last):
+  at 6:1, in apply
+  at 4:21, in inner`
+	if errObj.Inspect() != expectedInspect {
+		t.Errorf("wrong Inspect.\nexpected=%s\ngot=%s", expectedInspect, errObj.Inspect())
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n, acc) { if (n == 0) { return acc; } loop(n - 1, acc + n) }; loop(1000000, 0)", 500000500000},
+		{"let loop = fn(n) { if (n > 0) { return loop(n - 1) } n }; loop(1000000)", 0},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(1000001)", false},
+		{"let count = fn(n) { while (true) { if (n == 0) { return 7 } return count(n - 1) } }; count(100000)", 7},
+		{"let f = fn(n) { try { 1 } catch (e) { 2 } finally { return g(n) } }; let g = fn(n) { n * 2 }; f(5)", 10},
+		// Nel blocco try la chiamata non è in coda: il suo errore va catturato.
+		{"let f = fn(n) { try { return g(n) } catch (e) { e } }; let g = fn(n) { throw n }; f(5)", 5},
+		{"let f = fn(x) { x }; let g = fn() { f() }; g()", "wrong number of arguments. got=0, want=1"},
+		// Le funzioni built-in in coda vengono chiamate normalmente.
+		{"let f = fn(a) { len(a) }; f([1, 2])", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStackTraceBuiltinsAddNoFrames(t *testing.T) {
+	evaluated := testEval(`let f = fn() { len(1) }; f();`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Trace) != 1 || errObj.Trace[0].Function != "f" {
+		t.Errorf("wrong trace. got=%v", errObj.Trace)
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn(a, b) { a }(1)", "wrong number of arguments. got=1, want=2"},
+		{"fn(a) { a }(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"fn(a, b = 2) { a + b }()", "wrong number of arguments. got=0, want=1..2"},
+		{"fn(a, ...rest) { a }()", "wrong number of arguments. got=0, want=1+"},
+		{"fn(a, b = 2) { a + b }(1)", 3},
+		{"fn(a, b = 2) { a + b }(1, 5)", 6},
+		{"fn(a, b = a * 10) { b }(4)", 40},
+		{"let n = 7; fn(a = n) { a }()", 7},
+		{"fn(a = missing) { a }()", "identifier not found: missing"},
+		{"fn(...rest) { len(rest) }()", 0},
+		{"fn(a, ...rest) { len(rest) }(1, 2, 3)", 2},
+		{"fn(a, b = 2, ...rest) { a + b + rest[0] }(1, 10, 100)", 111},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestRestParameterCollectsArray(t *testing.T) {
+	evaluated := testEval(`let f = fn(first, ...rest) { rest }; f(1, 2, "tre")`)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if array.Inspect() != `[2, "tre"]` {
+		t.Errorf("wrong rest array. got=%s", array.Inspect())
+	}
+	if len(array.Elements) != 2 {
+		t.Errorf("wrong number of elements. got=%d", len(array.Elements))
+	}
+}
+
+// unknownNode è un nodo che l'evaluator non conosce, per simulare un AST estraneo.
+type unknownNode struct{ ast.Identifier }
+
+func TestMalformedASTProducesErrors(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{nil, "invalid program: missing node"},
+		{(*ast.BlockStatement)(nil), "invalid program: missing BlockStatement"},
+		{&ast.ExpressionStatement{}, "invalid program: missing node"},
+		{&ast.LetStatement{Name: &ast.Identifier{Value: "x"}}, "invalid program: missing node"},
+		{&ast.LetStatement{Value: &ast.IntegerLiteral{Value: 1}}, "invalid program: let statement without a name"},
+		{&ast.InfixExpression{Operator: "+", Left: &ast.IntegerLiteral{Value: 1}}, "invalid program: missing node"},
+		{&ast.IfExpression{Condition: &ast.Boolean{Value: true}}, "invalid program: missing BlockStatement"},
+		{&unknownNode{}, "unknown node type: *evaluator.unknownNode"},
+	}
+
+	for _, tt := range tests {
+		evaluated := Run(tt.node, object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %#v. got=%T(%+v)", tt.node, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestRunRecoversFromPanics(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("boom", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("kaboom")
+	}})
+
+	program := parser.New(lexer.New("let x = 1; boom(x)")).ParseProgram()
+	evaluated := Run(program, env)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "internal error: kaboom" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestEmptyBodiesEvaluateToNull(t *testing.T) {
+	tests := []string{"", "fn() {}()", "if (true) {}", "let f = fn() { while (false) {} }; f()"}
+
+	for _, input := range tests {
+		testNullObject(t, testEval(input))
+	}
+}
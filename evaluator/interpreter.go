@@ -0,0 +1,152 @@
+// File: evaluator/interpreter.go
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"monkey-interpreter/ast"
+	"monkey-interpreter/object"
+	"time"
+)
+
+// DefaultMaxDepth è la profondità di chiamata usata quando Limits.MaxDepth è zero.
+// Serve anche senza un budget esplicito: una ricorsione infinita esaurirebbe lo
+// stack di Go, e quell'errore termina il processo senza possibilità di recover.
+const DefaultMaxDepth = 10000
+
+// ctxCheckInterval indica ogni quanti passi controllare il contesto: farlo a ogni
+// nodo costerebbe quasi quanto la valutazione stessa.
+const ctxCheckInterval = 1024
+
+// Limits descrive le risorse concesse a un'esecuzione. Un campo a zero significa
+// "nessun limite", tranne MaxDepth che in quel caso vale DefaultMaxDepth.
+type Limits struct {
+	MaxSteps int64         // Numero massimo di nodi dell'AST valutati.
+	MaxDepth int           // Profondità massima delle chiamate di funzione annidate (non di quelle in coda).
+	Timeout  time.Duration // Tempo massimo di esecuzione, in aggiunta alla scadenza del contesto.
+	// MaxMemory è la quota, in byte stimati, degli oggetti allocati (vedi memory.go).
+	MaxMemory int64
+}
+
+/*
+Interpreter contiene lo stato di un'esecuzione: il budget da rispettare e i
+contatori che lo consumano. La valutazione vera e propria è nei suoi metodi
+(vedi evaluator.go); Eval e Run sono scorciatoie che ne creano uno al volo.
+
+Un Interpreter non va usato da più goroutine contemporaneamente.
+*/
+type Interpreter struct {
+	limits    Limits
+	ctx       context.Context
+	steps     int64
+	depth     int
+	allocated int64
+
+	// tailCalls è vero dove un return può diventare una chiamata in coda: nel
+	// corpo di una funzione, fuori dai blocchi try (vedi evalTail).
+	tailCalls bool
+}
+
+// NewInterpreter crea un interprete con i limiti indicati.
+func NewInterpreter(limits Limits) *Interpreter {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	return &Interpreter{limits: limits, ctx: context.Background()}
+}
+
+/*
+Run valuta node rispettando i limiti dell'interprete e interrompendosi quando
+ctx viene annullato o scade. Ogni chiamata riparte con i contatori azzerati.
+
+Superare un limite produce un *object.Error con un Kind dedicato, così che
+l'host possa distinguerlo dagli errori del programma. Nessun panic interno
+arriva al chiamante: un guasto imprevisto diventa un errore InternalError.
+*/
+func (in *Interpreter) Run(ctx context.Context, node ast.Node, env *object.Environment) (result object.Object) {
+	if in.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, in.limits.Timeout)
+		defer cancel()
+	}
+	in.ctx = ctx
+	in.steps = 0
+	in.depth = 0
+	in.allocated = 0
+	in.tailCalls = false
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{Kind: object.InternalError, Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+
+	if ctx.Err() != nil {
+		return in.contextError()
+	}
+	return in.eval(node, env)
+}
+
+// Steps restituisce il numero di nodi valutati dall'ultima chiamata a Run.
+func (in *Interpreter) Steps() int64 {
+	return in.steps
+}
+
+// EvalContext valuta node con un nuovo interprete configurato con limits.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return NewInterpreter(limits).Run(ctx, node, env)
+}
+
+/*
+Run è il punto d'ingresso pubblico per chi incorpora l'interprete. Valuta node
+come Eval, ma garantisce che nessun panic interno arrivi al chiamante: un
+guasto imprevisto dell'interprete diventa un errore Monkey "internal error".
+*/
+func Run(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env, Limits{})
+}
+
+// step conta un nodo valutato e verifica il limite di passi e il contesto.
+func (in *Interpreter) step() *object.Error {
+	in.steps++
+	if in.limits.MaxSteps > 0 && in.steps > in.limits.MaxSteps {
+		return newLimitError(object.StepLimitExceeded, "step limit exceeded: %d steps", in.limits.MaxSteps)
+	}
+	if in.steps%ctxCheckInterval == 0 {
+		select {
+		case <-in.ctx.Done():
+			return in.contextError()
+		default:
+		}
+	}
+	return nil
+}
+
+// enterCall registra l'ingresso in una funzione, rifiutandolo oltre la profondità massima.
+// Ogni enterCall riuscito va bilanciato da un exitCall.
+func (in *Interpreter) enterCall() *object.Error {
+	if in.depth >= in.limits.MaxDepth {
+		return newLimitError(object.DepthLimitExceeded, "maximum call depth exceeded: %d", in.limits.MaxDepth)
+	}
+	in.depth++
+	return nil
+}
+
+// exitCall registra l'uscita da una funzione.
+func (in *Interpreter) exitCall() {
+	in.depth--
+}
+
+// contextError traduce il motivo per cui il contesto è terminato in un errore.
+func (in *Interpreter) contextError() *object.Error {
+	if errors.Is(in.ctx.Err(), context.DeadlineExceeded) {
+		return newLimitError(object.DeadlineExceeded, "execution deadline exceeded")
+	}
+	return newLimitError(object.Canceled, "execution canceled")
+}
+
+// newLimitError crea un errore per un limite di risorse superato.
+func newLimitError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"context"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+	"time"
+)
+
+func testEvalWithLimits(ctx context.Context, input string, limits Limits) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return EvalContext(ctx, program, object.NewEnvironment(), limits)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input        string
+		limits       Limits
+		expectedKind object.ErrorKind
+		expectedMsg  string
+	}{
+		{"let f = fn() { 1 + f() }; f()", Limits{}, object.DepthLimitExceeded, "maximum call depth exceeded: 10000"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", Limits{MaxDepth: 50}, object.DepthLimitExceeded, "maximum call depth exceeded: 50"},
+		// Una ricorsione in coda non consuma profondità: come un ciclo, la fermano i passi o il tempo.
+		{"let f = fn() { f() }; f()", Limits{MaxSteps: 1000}, object.StepLimitExceeded, "step limit exceeded: 1000 steps"},
+		{"let f = fn(n) { return f(n + 1) }; f(0)", Limits{Timeout: 20 * time.Millisecond}, object.DeadlineExceeded, "execution deadline exceeded"},
+		{"while (true) {}", Limits{MaxSteps: 1000}, object.StepLimitExceeded, "step limit exceeded: 1000 steps"},
+		{"let i = 0; while (true) { i += 1 }", Limits{Timeout: 20 * time.Millisecond}, object.DeadlineExceeded, "execution deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithLimits(context.Background(), tt.input, tt.limits)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind for %q. expected=%s, got=%s", tt.input, tt.expectedKind, errObj.Kind)
+		}
+		if errObj.Message != tt.expectedMsg {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expectedMsg, errObj.Message)
+		}
+	}
+}
+
+func TestLimitErrorsAreNotCaught(t *testing.T) {
+	tests := []struct {
+		input        string
+		limits       Limits
+		expectedKind object.ErrorKind
+	}{
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { 1 }", Limits{MaxDepth: 50}, object.DepthLimitExceeded},
+		{"let x = 0; try { while (true) { } } catch (e) { 1 } finally { x = 1 }", Limits{MaxSteps: 1000}, object.StepLimitExceeded},
+		{"try { while (true) { } } catch (e) { 1 }", Limits{Timeout: 10 * time.Millisecond}, object.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithLimits(context.Background(), tt.input, tt.limits)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("%q: wrong kind. expected=%s, got=%s", tt.input, tt.expectedKind, errObj.Kind)
+		}
+	}
+}
+
+func TestLimitsAllowProgramsWithinBudget(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(10)`
+
+	evaluated := testEvalWithLimits(context.Background(), input, Limits{MaxSteps: 100000, MaxDepth: 20})
+	testIntegerObject(t, evaluated, 55)
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	evaluated := testEvalWithLimits(ctx, "while (true) {}", Limits{})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.Canceled {
+		t.Errorf("wrong error kind. expected=%s, got=%s", object.Canceled, errObj.Kind)
+	}
+}
+
+func TestInterpreterCountsSteps(t *testing.T) {
+	program := parser.New(lexer.New("1 + 2")).ParseProgram()
+	in := NewInterpreter(Limits{})
+	in.Run(context.Background(), program, object.NewEnvironment())
+
+	// Program, ExpressionStatement, InfixExpression e i due letterali.
+	if in.Steps() != 5 {
+		t.Errorf("wrong number of steps. expected=5, got=%d", in.Steps())
+	}
+}
@@ -0,0 +1,130 @@
+// File: evaluator/memory.go
+package evaluator
+
+import (
+	"math"
+	"monkey-interpreter/object"
+)
+
+/*
+Stime, in byte, dell'occupazione degli oggetti. Non pretendono di essere esatte:
+servono a far crescere il conteggio in proporzione a quanto un programma alloca
+davvero, così che la quota di memoria fermi chi costruisce strutture enormi.
+*/
+const (
+	objectHeaderSize = 16 // Un oggetto piccolo (Integer, Float) e l'interfaccia che lo contiene.
+	sliceSlotSize    = 16 // Un elemento di un array: il valore di un'interfaccia.
+	hashPairSize     = 64 // Una coppia chiave/valore, con la sua voce nella mappa e nell'ordine.
+	bindingSize      = 48 // Un nome legato in un Environment.
+	environmentSize  = 64 // Un Environment vuoto.
+	functionSize     = 64 // Una chiusura, senza il suo ambiente (contato quando è stato creato).
+)
+
+/*
+sizeOf stima la dimensione "superficiale" di un oggetto: un array conta i suoi
+elementi come riferimenti, perché gli elementi sono già stati contati quando
+sono stati creati. I singleton (TRUE, FALSE, NULL), gli interi della cache di
+object.NewInteger, gli errori e i segnali interni non costano nulla.
+*/
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		if object.IsCachedInteger(obj.Value) {
+			return 0
+		}
+		return objectHeaderSize
+	case *object.Float:
+		return objectHeaderSize
+	case *object.BigInteger:
+		return objectHeaderSize + int64(len(obj.Value.Bits()))*8
+	case *object.String:
+		return objectHeaderSize + int64(len(obj.Value))
+	case *object.Array:
+		return objectHeaderSize + int64(len(obj.Elements))*sliceSlotSize
+	case *object.Hash:
+		return objectHeaderSize + int64(len(obj.Order))*hashPairSize
+	case *object.Function:
+		return functionSize
+	case *object.ErrorValue:
+		return objectHeaderSize + int64(len(obj.Message))
+	default:
+		return 0
+	}
+}
+
+// Allocated restituisce la stima dei byte allocati dall'ultima chiamata a Run.
+// È un totale cumulativo: la memoria liberata dal garbage collector non viene sottratta.
+func (in *Interpreter) Allocated() int64 {
+	return in.allocated
+}
+
+// alloc aggiunge size byte al totale allocato, fallendo se supera la quota.
+func (in *Interpreter) alloc(size int64) *object.Error {
+	in.allocated += size
+	if in.limits.MaxMemory > 0 && in.allocated > in.limits.MaxMemory {
+		return newLimitError(object.OutOfMemory, "out of memory: allocated %d bytes, quota is %d",
+			in.allocated, in.limits.MaxMemory)
+	}
+	return nil
+}
+
+// track conta un oggetto appena creato e lo restituisce, oppure restituisce
+// l'errore di memoria esaurita se l'oggetto non sta nella quota.
+func (in *Interpreter) track(obj object.Object) object.Object {
+	if err := in.alloc(sizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+/*
+infix valuta un'operazione binaria tenendo conto della memoria. Per ** e <<
+il risultato può essere enormemente più grande degli operandi, quindi ne
+stimiamo la dimensione in anticipo e rifiutiamo il calcolo se non sta nella
+quota, invece di scoprirlo dopo averlo già allocato.
+*/
+func (in *Interpreter) infix(operator string, left, right object.Object) object.Object {
+	if in.limits.MaxMemory > 0 {
+		estimate := estimateInfixSize(operator, left, right)
+		if estimate > in.limits.MaxMemory-in.allocated {
+			return newLimitError(object.OutOfMemory, "out of memory: %s would need about %d bytes, quota is %d",
+				operator, estimate, in.limits.MaxMemory)
+		}
+	}
+	return in.track(evalInfixExpression(operator, left, right))
+}
+
+// estimateInfixSize stima in byte il risultato di ** e << tra interi; per le
+// altre operazioni restituisce 0, perché il risultato cresce al più linearmente.
+func estimateInfixSize(operator string, left, right object.Object) int64 {
+	if left.Type() != object.INTEGER_OBJ || right.Type() != object.INTEGER_OBJ {
+		return 0
+	}
+
+	base := toBigInt(left)
+	count := toBigInt(right)
+	if count.Sign() <= 0 || !count.IsInt64() {
+		// Esponenti negativi danno un FLOAT, shift enormi sono già un errore.
+		return 0
+	}
+
+	bits := int64(base.BitLen())
+	switch operator {
+	case "**":
+		if bits <= 1 {
+			// 0, 1 e -1 elevati a qualunque potenza restano piccoli.
+			return 0
+		}
+		if count.Int64() > math.MaxInt64/bits {
+			return math.MaxInt64
+		}
+		return bits * count.Int64() / 8
+	case "<<":
+		if count.Int64() > math.MaxInt64-bits {
+			return math.MaxInt64
+		}
+		return (bits + count.Int64()) / 8
+	default:
+		return 0
+	}
+}
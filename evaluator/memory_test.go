@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"context"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+)
+
+func TestMemoryQuota(t *testing.T) {
+	tests := []struct {
+		input string
+		quota int64
+	}{
+		{`let a = []; while (true) { a = push(a, 1) }`, 100000},
+		{`let s = "x"; while (true) { s = s + s }`, 100000},
+		{`let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }`, 100000},
+		{`let f = fn(n) { let g = fn() { n }; f(n + 1) }; f(0)`, 100000},
+		{`2 ** 100000000`, 1000000},
+		{`1 << 100000000`, 1000000},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithLimits(context.Background(), tt.input, Limits{MaxMemory: tt.quota})
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != object.OutOfMemory {
+			t.Errorf("wrong error kind for %q. expected=%s, got=%s (%s)", tt.input, object.OutOfMemory, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestAllocatedBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// Stringa (16 + 3) e il legame del nome (48).
+		{`let s = "abc";`, 67},
+		// Chiusura (64) e legame (48), ambiente della chiamata con un parametro (64 + 48).
+		// L'argomento 1 viene dalla cache degli interi e non costa nulla.
+		{`let f = fn(x) { x }; f(1)`, 224},
+		// L'array con tre elementi (16 + 3 * 16); gli interi sono nella cache.
+		{`[1, 2, 3]`, 64},
+		// Tre interi fuori dalla cache (3 * 16) e l'array (16 + 3 * 16).
+		{`[2000, 3000, 4000]`, 112},
+		// Gli interi restano entro la quota anche quando ** produce un risultato grande ma ammesso:
+		// conta solo l'esponente, l'unico fuori dalla cache.
+		{`1 ** 100000000`, 16},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		in := NewInterpreter(Limits{MaxMemory: 1 << 20})
+		result := in.Run(context.Background(), program, object.NewEnvironment())
+		if isError(result) {
+			t.Errorf("unexpected error for %q: %s", tt.input, result.Inspect())
+			continue
+		}
+		if in.Allocated() != tt.expected {
+			t.Errorf("wrong allocated bytes for %q. expected=%d, got=%d", tt.input, tt.expected, in.Allocated())
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// File: evaluator/operations.go
+package evaluator
+
+import (
+	"monkey-interpreter/ast"
+	"monkey-interpreter/object"
+	"monkey-interpreter/token"
+)
+
+/*
+Le funzioni di questo file espongono la semantica dei valori Monkey agli altri
+motori di esecuzione (il compilatore e la VM), così che un programma dia gli
+stessi risultati e gli stessi errori qualunque motore lo esegua.
+*/
+
+// InfixOperation applica un operatore binario, es. InfixOperation("+", a, b).
+func InfixOperation(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// PrefixOperation applica un operatore prefisso, es. PrefixOperation("-", x).
+func PrefixOperation(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// IndexOperation legge left[index] da un array, una mappa o un valore di errore.
+func IndexOperation(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// IsTruthy dice se un valore conta come vero in una condizione: lo sono tutti
+// tranne `false` e `null`.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// LookupBuiltin restituisce la funzione built-in con il nome dato.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// CaughtValue restituisce il valore che un blocco catch vede per err: il valore
+// lanciato con `throw`, oppure una mappa con i campi dell'errore.
+func CaughtValue(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	return errorToHash(err)
+}
+
+// ErrorSpan restituisce la porzione di sorgente a cui attribuire un errore
+// prodotto valutando node.
+func ErrorSpan(node ast.Node) (token.Position, token.Position) {
+	return errorSpan(node)
+}
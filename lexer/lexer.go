@@ -1,6 +1,11 @@
 package lexer
 
-import "monkey-interpreter/token"
+import (
+	"monkey-interpreter/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 // Lexer è responsabile della tokenizzazione dell'input. Analizza la stringa di input carattere per carattere
 // e genera una sequenza di token che rappresentano i costrutti sintattici del linguaggio.
@@ -9,17 +14,58 @@ type Lexer struct {
 	position     int    // La posizione attuale nell'input (punta al carattere corrente)
 	readPosition int    // La posizione futura nell'input (punta al prossimo carattere da leggere)
 	ch           byte   // Il carattere corrente che il lexer sta esaminando
+
+	filename   string         // Il nome del file, riportato in ogni token.Position
+	line       int            // La riga del carattere corrente (da 1)
+	column     int            // La colonna, in rune, del carattere corrente (da 1)
+	tokenStart token.Position // La posizione in cui inizia il token in lettura
+
+	keepComments bool          // Se true, i commenti vengono conservati come trivia
+	trivia       []token.Token // I commenti letti e non ancora allegati a un token
+}
+
+// Option configura un aspetto facoltativo del Lexer.
+type Option func(*Lexer)
+
+// WithComments fa sì che il lexer non scarti i commenti ma li alleghi, come token COMMENT,
+// al campo Trivia del token che li segue. È pensata per strumenti (formattatori,
+// generatori di documentazione) che devono ricostruire il sorgente senza perdere i commenti.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.keepComments = true
+	}
+}
+
+// WithFilename imposta il nome del file riportato nelle posizioni dei token.
+func WithFilename(filename string) Option {
+	return func(l *Lexer) {
+		l.filename = filename
+	}
 }
 
 // New crea e restituisce un nuovo Lexer inizializzato con l'input fornito.
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	for _, opt := range opts {
+		opt(l)
+	}
 	l.readChar() // Inizializza il primo carattere in 'ch'
 	return l
 }
 
 // readChar legge il prossimo carattere nell'input e aggiorna le posizioni del lexer.
 func (l *Lexer) readChar() {
+	// Aggiorna riga e colonna del carattere che sta per diventare corrente. "\r\n" conta come
+	// un solo a capo, e i byte di continuazione UTF-8 non fanno avanzare la colonna.
+	if l.readPosition <= len(l.input) {
+		if l.ch == '\n' || (l.ch == '\r' && l.peekChar() != '\n') {
+			l.line++
+			l.column = 1
+		} else if l.readPosition == len(l.input) || utf8.RuneStart(l.input[l.readPosition]) {
+			l.column++
+		}
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // ASCII NUL (0x00) usato come EOF
 	} else {
@@ -34,11 +80,33 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// NextToken restituisce il prossimo token presente nell'input.
-// Esamina il carattere corrente e lo trasforma nel corrispondente token.
+// currentPosition restituisce la posizione del carattere corrente.
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column, Offset: l.position}
+}
+
+// NextToken restituisce il prossimo token presente nell'input, con la sua posizione e
+// gli eventuali commenti che lo precedono (solo se il lexer li conserva).
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	tok.Pos = l.tokenStart
+	tok.End = l.currentPosition()
+	if len(l.trivia) > 0 {
+		tok.Trivia = l.trivia
+		l.trivia = nil
+	}
+	return tok
+}
+
+// nextToken esamina il carattere corrente e lo trasforma nel corrispondente token.
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
-	l.skipWhitespace() // Ignora spazi bianchi per identificare il prossimo token significativo.
+
+	// Ignora spazi bianchi e commenti per identificare il prossimo token significativo.
+	if comment, ok := l.skipWhitespace(); !ok {
+		return token.Token{Type: token.ILLEGAL, Literal: comment, Problem: "unterminated block comment"}
+	}
+	l.tokenStart = l.currentPosition()
 
 	switch l.ch {
 	case '=':
@@ -53,35 +121,122 @@ func (l *Lexer) NextToken() token.Token {
 
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		// Un punto può aprire un numero (".5") o far parte di "..." (parametro variadico)
+		if isDigit(l.peekChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			return tok
+		} else if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		// Verifica se è "+=" (assegnamento composto)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.DASH, l.ch)
+		// Verifica se è "-=" (assegnamento composto)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.DASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.STAR, l.ch)
+		// Verifica se è "**" (elevamento a potenza) o "*=" (assegnamento composto)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.STAR_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.STAR, l.ch)
+		}
 	case '/':
-		tok = newToken(token.FORWARDSLASH, l.ch)
+		// Verifica se è "/=" (assegnamento composto)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.FORWARDSLASH, l.ch)
+		}
+	case '%':
+		// Verifica se è "%=" (assegnamento composto)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PERCENT_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PERCENT, l.ch)
+		}
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
+	case '&':
+		// Verifica se è "&&" (and logico) o "&" (and bit a bit)
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		// Verifica se è "||" (or logico) o "|" (or bit a bit)
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
 	case '<':
-		// Controlla se è l'operatore "<=" (minore o uguale)
+		// Controlla se è l'operatore "<=" (minore o uguale) o "<<" (shift a sinistra)
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '<' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.LT, l.ch) // Altrimenti è solo "<"
 		}
 	case '>':
-		// Controlla se è l'operatore ">=" (maggiore o uguale)
+		// Controlla se è l'operatore ">=" (maggiore o uguale) o ">>" (shift a destra)
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.GT, l.ch) // Altrimenti è solo ">"
 		}
@@ -89,6 +244,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '!':
 		// Verifica se è "!=" (diverso)
 		if l.peekChar() == '=' {
@@ -98,6 +257,14 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch) // Altrimenti è solo "!"
 		}
+	case '"':
+		// Letterale stringa: il valore del token è il contenuto già decodificato
+		literal, problem := l.readString()
+		if problem == "" {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: literal, Problem: problem}
+		}
 	case 0:
 		// Raggiunto la fine dell'input
 		tok = token.Token{Type: token.EOF, Literal: ""}
@@ -108,11 +275,15 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal) // Verifica se è una parola chiave
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber() // È un numero intero
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber() // È un numero intero o in virgola mobile
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch) // Token non riconosciuto
+			// Token non riconosciuto: il letterale è l'intero carattere, anche se multi-byte
+			_, size := utf8.DecodeRuneInString(l.input[l.position:])
+			for i := 1; i < size; i++ {
+				l.readChar()
+			}
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position-size+1 : l.position+1]}
 		}
 	}
 
@@ -129,20 +300,205 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// readNumber legge un intero dall'input e lo restituisce come stringa.
-func (l *Lexer) readNumber() string {
+// readNumber legge un numero dall'input e lo restituisce come stringa insieme al tipo di token.
+// Un numero è FLOAT se contiene una parte decimale (es. "3.14", ".5") o un esponente
+// (es. "1e-9"); altrimenti è INT. Il punto conta come parte decimale solo se seguito da una cifra.
+// Un numero seguito senza spazi da una lettera o da un altro punto (es. "1.5.2", "1e", "0x1F")
+// è malformato: il token è ILLEGAL e comprende tutto il testo attaccato al numero.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	if (l.ch == 'e' || l.ch == 'E') && l.exponentFollows() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	if isLetter(l.ch) || (l.ch == '.' && (isDigit(l.peekChar()) || isLetter(l.peekChar()))) {
+		for isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' {
+			l.readChar()
+		}
+		tokenType = token.ILLEGAL
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+// exponentFollows verifica, con il lexer posizionato su una 'e', che segua un esponente
+// valido: una cifra, oppure un segno seguito da una cifra.
+func (l *Lexer) exponentFollows() bool {
+	next := l.peekChar()
+	if isDigit(next) {
+		return true
+	}
+	if (next == '+' || next == '-') && l.readPosition+1 < len(l.input) {
+		return isDigit(l.input[l.readPosition+1])
+	}
+	return false
+}
+
+// readString legge un letterale stringa racchiuso tra doppi apici, decodificando le sequenze
+// di escape supportate (\n, \t, \", \\ e \u{...}). Al ritorno il lexer è posizionato sul
+// doppio apice di chiusura. Se la stringa non è terminata o contiene una sequenza di escape non
+// valida, il secondo valore descrive il problema e il letterale è il testo sorgente grezzo.
+func (l *Lexer) readString() (string, string) {
+	var out strings.Builder
+	start := l.position + 1
+	problem := ""
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			if problem != "" {
+				return l.input[start:l.position], problem
+			}
+			return out.String(), ""
+		case 0:
+			return l.input[start:l.position], "unterminated string literal"
+		case '\\':
+			escape := l.position
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, ok := l.readUnicodeEscape()
+				if !ok {
+					if problem == "" {
+						problem = "invalid unicode escape `" + l.input[escape:l.readPosition] + "`"
+					}
+					continue
+				}
+				out.WriteRune(r)
+			case 0:
+				return l.input[start:l.position], "unterminated string literal"
+			default:
+				if problem == "" {
+					_, size := utf8.DecodeRuneInString(l.input[l.position:])
+					problem = "invalid escape sequence `" + l.input[escape:l.position+size] + "`"
+				}
+			}
+		default:
+			out.WriteByte(l.ch)
+		}
+	}
+}
+
+// readUnicodeEscape decodifica la parte "{...}" di una sequenza \u{...}, dove tra le graffe
+// compare il codice esadecimale di un carattere Unicode (es. \u{1F600}).
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return 0, false
+	}
+	l.readChar()
+	start := l.readPosition
+	for l.peekChar() != '}' {
+		if l.peekChar() == 0 || l.peekChar() == '"' {
+			return 0, false
+		}
+		l.readChar()
+	}
+	digits := l.input[start:l.readPosition]
+	l.readChar() // Consuma la '}'
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+// skipWhitespace salta gli spazi bianchi come spazi, tabulazioni e nuove righe, e i commenti
+// ("// fino a fine riga" e "/* a blocco */", anche annidati). Se un commento a blocco non
+// viene chiuso restituisce false insieme al testo del commento.
+func (l *Lexer) skipWhitespace() (string, bool) {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			start := l.currentPosition()
+			l.addTrivia(l.readLineComment(), start)
+		case l.ch == '/' && l.peekChar() == '*':
+			start := l.currentPosition()
+			comment, ok := l.readBlockComment()
+			if !ok {
+				l.tokenStart = start
+				return comment, false
+			}
+			l.addTrivia(comment, start)
+		default:
+			return "", true
+		}
+	}
+}
+
+// readLineComment legge un commento "//" fino alla fine della riga (esclusa).
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
 	return l.input[position:l.position]
 }
 
-// skipWhitespace salta gli spazi bianchi come spazi, tabulazioni e nuove righe.
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// readBlockComment legge un commento "/* ... */", delimitatori inclusi. I commenti a blocco
+// possono essere annidati: "/* a /* b */ c */" è un unico commento.
+func (l *Lexer) readBlockComment() (string, bool) {
+	position := l.position
+	depth := 0
+	for l.ch != 0 {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return l.input[position:l.position], true
+			}
+		}
 		l.readChar()
 	}
+	return l.input[position:l.position], false
+}
+
+// addTrivia conserva un commento come trivia, se il lexer è configurato per farlo.
+func (l *Lexer) addTrivia(comment string, start token.Position) {
+	if l.keepComments {
+		l.trivia = append(l.trivia, token.Token{
+			Type:    token.COMMENT,
+			Literal: comment,
+			Pos:     start,
+			End:     l.currentPosition(),
+		})
+	}
 }
 
 // isLetter verifica se il carattere è una lettera (minuscola, maiuscola o underscore).
@@ -7,7 +7,8 @@ import (
 
 // TestConditionals testa il lexer con varie espressioni condizionali e operatori.
 func TestConditionals(t *testing.T) {
-	input := `!-/*5     
+	// "/ *" è separato da uno spazio: "/*" aprirebbe un commento a blocco.
+	input := `!-/ *5     
     5<10<5;
     if(5<10){
     return true;
@@ -149,3 +150,361 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+// TestStrings verifica il riconoscimento delle stringhe e la decodifica delle sequenze di escape.
+func TestStrings(t *testing.T) {
+	input := `"foobar"
+	"foo bar"
+	"riga\nnuova\ttab"
+	"lui disse \"ciao\" \\ fine"
+	"\u{48}\u{e8}\u{1F600}"
+	""
+	"escape \q non valido"
+	"\u{110000} fuori intervallo"
+	"non terminata`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedProblem string
+	}{
+		{token.STRING, "foobar", ""},
+		{token.STRING, "foo bar", ""},
+		{token.STRING, "riga\nnuova\ttab", ""},
+		{token.STRING, `lui disse "ciao" \ fine`, ""},
+		{token.STRING, "Hè😀", ""},
+		{token.STRING, "", ""},
+		{token.ILLEGAL, `escape \q non valido`, "invalid escape sequence `\\q`"},
+		{token.ILLEGAL, `\u{110000} fuori intervallo`, "invalid unicode escape `\\u{110000}`"},
+		{token.ILLEGAL, "non terminata", "unterminated string literal"},
+		{token.EOF, "", ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Problem != tt.expectedProblem {
+			t.Fatalf("tests[%d] - problema errato. Atteso=%q, ottenuto=%q", i, tt.expectedProblem, tok.Problem)
+		}
+	}
+}
+
+// TestBrackets verifica il riconoscimento dei delimitatori usati da array, indici e mappe.
+func TestBrackets(t *testing.T) {
+	input := `[1, 2][0]; {"a": 1}`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// TestNumbers verifica il riconoscimento di interi e numeri in virgola mobile.
+func TestNumbers(t *testing.T) {
+	input := `3.14 1e-9 2E+3 .5 10 1.x 5e 1.5.2 0x1F 1e+ 2.5e 7_000 [1...]`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2E+3"},
+		{token.FLOAT, ".5"},
+		{token.INT, "10"},
+		{token.ILLEGAL, "1.x"},
+		{token.ILLEGAL, "5e"},
+		{token.ILLEGAL, "1.5.2"},
+		{token.ILLEGAL, "0x1F"},
+		{token.ILLEGAL, "1e"},
+		{token.PLUS, "+"},
+		{token.ILLEGAL, "2.5e"},
+		{token.ILLEGAL, "7_000"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.ELLIPSIS, "..."},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// TestOperators verifica il riconoscimento degli operatori aritmetici, logici e bit a bit.
+func TestOperators(t *testing.T) {
+	input := `% ** * && & || | ^ ~ ? << <= < >> >= > ... .5 .`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.STAR, "*"},
+		{token.AND, "&&"},
+		{token.AMPERSAND, "&"},
+		{token.OR, "||"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.QUESTION, "?"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.LT_EQ, "<="},
+		{token.LT, "<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.GT_EQ, ">="},
+		{token.GT, ">"},
+		{token.ELLIPSIS, "..."},
+		{token.FLOAT, ".5"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// TestAssignmentOperators verifica il riconoscimento degli operatori di assegnamento composto.
+func TestAssignmentOperators(t *testing.T) {
+	input := `x += 1; x -= 1; x *= 2; x /= 2; x %= 3; x = -1;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.STAR_ASSIGN, "*="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.PERCENT_ASSIGN, "%="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.DASH, "-"}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// TestComments verifica che i commenti di riga e a blocco (anche annidati) vengano ignorati.
+func TestComments(t *testing.T) {
+	input := `// commento iniziale
+let x = 5; // commento a fine riga
+/* commento
+   su più righe */ x /* in mezzo */ / 2;
+/* esterno /* annidato */ ancora esterno */ x
+/* non chiuso`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.FORWARDSLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ILLEGAL, "/* non chiuso"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - valore letterale errato. Atteso=%q, ottenuto=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(tok.Trivia) != 0 {
+			t.Fatalf("tests[%d] - trivia inattesa senza WithComments: %v", i, tok.Trivia)
+		}
+	}
+}
+
+// TestUnterminatedComment verifica che un commento a blocco non chiuso diventi un token
+// ILLEGAL che parte dall'apertura del commento e ne spiega il motivo.
+func TestUnterminatedComment(t *testing.T) {
+	l := New("x\n  /* aperto /* annidato */\nancora")
+
+	if tok := l.NextToken(); tok.Type != token.IDENT {
+		t.Fatalf("primo token errato: %q", tok.Type)
+	}
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Problem != "unterminated block comment" {
+		t.Fatalf("token errato. ottenuto=%q %q", tok.Type, tok.Problem)
+	}
+	if tok.Pos.Line != 2 || tok.Pos.Column != 3 {
+		t.Errorf("posizione errata. Attesa=2:3, ottenuta=%s", tok.Pos)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Errorf("atteso EOF, ottenuto=%q", tok.Type)
+	}
+}
+
+// TestCommentTrivia verifica che, con WithComments, i commenti vengano allegati al token successivo.
+func TestCommentTrivia(t *testing.T) {
+	input := `// doc di x
+/* a /* b */ c */
+let x = 1; // coda
+// fine`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedTrivia []string
+	}{
+		{token.LET, []string{"// doc di x", "/* a /* b */ c */"}},
+		{token.IDENT, nil},
+		{token.ASSIGN, nil},
+		{token.INT, nil},
+		{token.SEMICOLON, nil},
+		{token.EOF, []string{"// coda", "// fine"}},
+	}
+
+	l := New(input, WithComments())
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if len(tok.Trivia) != len(tt.expectedTrivia) {
+			t.Fatalf("tests[%d] - numero di commenti errato. Atteso=%d, ottenuto=%d", i, len(tt.expectedTrivia), len(tok.Trivia))
+		}
+
+		for j, comment := range tok.Trivia {
+			if comment.Type != token.COMMENT {
+				t.Errorf("tests[%d] - trivia[%d] non è COMMENT. ottenuto=%q", i, j, comment.Type)
+			}
+			if comment.Literal != tt.expectedTrivia[j] {
+				t.Errorf("tests[%d] - trivia[%d] errata. Atteso=%q, ottenuto=%q", i, j, tt.expectedTrivia[j], comment.Literal)
+			}
+		}
+	}
+}
+
+// TestPositions verifica riga, colonna e offset dei token, anche con \r\n e caratteri UTF-8 multi-byte.
+func TestPositions(t *testing.T) {
+	input := "let x = 1;\r\nlet è = \"ü\";\n  x"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Filename: "test.mk", Line: 1, Column: 1, Offset: 0}, token.Position{Filename: "test.mk", Line: 1, Column: 4, Offset: 3}},
+		{token.IDENT, token.Position{Filename: "test.mk", Line: 1, Column: 5, Offset: 4}, token.Position{Filename: "test.mk", Line: 1, Column: 6, Offset: 5}},
+		{token.ASSIGN, token.Position{Filename: "test.mk", Line: 1, Column: 7, Offset: 6}, token.Position{Filename: "test.mk", Line: 1, Column: 8, Offset: 7}},
+		{token.INT, token.Position{Filename: "test.mk", Line: 1, Column: 9, Offset: 8}, token.Position{Filename: "test.mk", Line: 1, Column: 10, Offset: 9}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Line: 1, Column: 10, Offset: 9}, token.Position{Filename: "test.mk", Line: 1, Column: 11, Offset: 10}},
+		{token.LET, token.Position{Filename: "test.mk", Line: 2, Column: 1, Offset: 12}, token.Position{Filename: "test.mk", Line: 2, Column: 4, Offset: 15}},
+		{token.ILLEGAL, token.Position{Filename: "test.mk", Line: 2, Column: 5, Offset: 16}, token.Position{Filename: "test.mk", Line: 2, Column: 6, Offset: 18}},
+		{token.ASSIGN, token.Position{Filename: "test.mk", Line: 2, Column: 7, Offset: 19}, token.Position{Filename: "test.mk", Line: 2, Column: 8, Offset: 20}},
+		{token.STRING, token.Position{Filename: "test.mk", Line: 2, Column: 9, Offset: 21}, token.Position{Filename: "test.mk", Line: 2, Column: 12, Offset: 25}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Line: 2, Column: 12, Offset: 25}, token.Position{Filename: "test.mk", Line: 2, Column: 13, Offset: 26}},
+		{token.IDENT, token.Position{Filename: "test.mk", Line: 3, Column: 3, Offset: 29}, token.Position{Filename: "test.mk", Line: 3, Column: 4, Offset: 30}},
+	}
+
+	l := New(input, WithFilename("test.mk"))
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tipo token errato. Atteso=%q, ottenuto=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - posizione iniziale errata. Atteso=%+v, ottenuto=%+v", i, tt.expectedPos, tok.Pos)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - posizione finale errata. Atteso=%+v, ottenuto=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
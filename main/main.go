@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"monkey-interpreter/repl"
 	"os"
@@ -8,6 +9,15 @@ import (
 )
 
 func main() {
+	engineName := flag.String("engine", string(repl.EngineEval), "execution engine: \"eval\" (tree-walking), \"resolved\" (tree-walking with static scope resolution) or \"vm\" (bytecode)")
+	flag.Parse()
+
+	engine, err := repl.ParseEngine(*engineName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	user, err := user.Current()
 
 	if err != nil {
@@ -15,5 +25,5 @@ func main() {
 	}
 	fmt.Printf("Hello %s\n, This is the Monkey Programming language", user.Username)
 	fmt.Printf("Write something\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithEngine(os.Stdin, os.Stdout, engine)
 }
@@ -5,7 +5,14 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey-interpreter/ast"
+	"monkey-interpreter/code"
+	"monkey-interpreter/diagnostics"
+	"monkey-interpreter/token"
+	"strconv"
 	"strings"
 )
 
@@ -22,11 +29,22 @@ type Object interface {
 // Definiamo delle costanti per i tipi di oggetto, così evitiamo errori di battitura.
 const (
 	INTEGER_OBJ      = "INTEGER"      // Per i numeri interi
+	FLOAT_OBJ        = "FLOAT"        // Per i numeri in virgola mobile
 	BOOLEAN_OBJ      = "BOOLEAN"      // Per i valori vero/falso
 	NULL_OBJ         = "NULL"         // Per il valore nullo `null`
 	RETURN_VALUE_OBJ = "RETURN_VALUE" // Un tipo speciale per gestire le istruzioni `return`
 	ERROR_OBJ        = "ERROR"        // Per gestire gli errori di runtime
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"  // Per gli errori come valori, creati con `error(...)`
 	FUNCTION_OBJ     = "FUNCTION"     // Il nuovo tipo per rappresentare le funzioni
+	STRING_OBJ       = "STRING"       // Per le stringhe di testo
+	ARRAY_OBJ        = "ARRAY"        // Per le liste ordinate di valori
+	HASH_OBJ         = "HASH"         // Per le mappe chiave/valore
+	BUILTIN_OBJ      = "BUILTIN"      // Per le funzioni native scritte in Go
+	BREAK_OBJ        = "BREAK"        // Segnale interno prodotto da `break`
+	CONTINUE_OBJ     = "CONTINUE"     // Segnale interno prodotto da `continue`
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION" // Il corpo compilato di una funzione, per la VM
+	CELL_OBJ              = "CELL"              // Una variabile catturata da una chiusura della VM
 )
 
 // --- DEFINIZIONI DELLE STRUCT PER OGNI TIPO DI OGGETTO ---
@@ -40,6 +58,84 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// Estremi (inclusi) degli interi preallocati da NewInteger.
+const (
+	MinCachedInteger = -128
+	MaxCachedInteger = 1023
+)
+
+// smallIntegers contiene gli interi tra MinCachedInteger e MaxCachedInteger, creati
+// una volta sola: contatori, indici e piccoli risultati non allocano.
+var smallIntegers = func() []Integer {
+	cache := make([]Integer, MaxCachedInteger-MinCachedInteger+1)
+	for i := range cache {
+		cache[i].Value = int64(i + MinCachedInteger)
+	}
+	return cache
+}()
+
+// NewInteger restituisce un *Integer con il valore dato. I valori piccoli vengono
+// dalla cache e sono condivisi, per questo nessuno deve modificare Value di un
+// Integer dopo averlo creato.
+func NewInteger(value int64) *Integer {
+	if IsCachedInteger(value) {
+		return &smallIntegers[value-MinCachedInteger]
+	}
+	return &Integer{Value: value}
+}
+
+// IsCachedInteger riporta se NewInteger restituisce per value un oggetto della cache.
+func IsCachedInteger(value int64) bool {
+	return value >= MinCachedInteger && value <= MaxCachedInteger
+}
+
+// BigInteger rappresenta un intero che non entra in un int64. Per il codice Monkey è
+// indistinguibile da Integer (stesso tipo INTEGER): l'interprete passa da una
+// rappresentazione all'altra in automatico, tramite IntegerFromBig.
+type BigInteger struct {
+	Value *big.Int // Il valore numerico effettivo; non va modificato dopo la creazione.
+}
+
+// Implementazione dell'interfaccia Object per BigInteger.
+func (bi *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+
+// IntegerFromBig restituisce un *Integer se il valore entra in un int64 e un
+// *BigInteger altrimenti, così che i valori piccoli usino sempre la rappresentazione veloce.
+func IntegerFromBig(value *big.Int) Object {
+	if value.IsInt64() {
+		return NewInteger(value.Int64())
+	}
+	return &BigInteger{Value: value}
+}
+
+// Float rappresenta un numero in virgola mobile a 64 bit.
+type Float struct {
+	Value float64 // Il valore numerico effettivo.
+}
+
+// Implementazione dell'interfaccia Object per Float.
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect usa la rappresentazione più corta che identifica il valore, aggiungendo ".0"
+// ai valori interi (così 2.0 non si confonde con l'intero 2). NaN e infiniti sono
+// mostrati come "NaN", "Inf" e "-Inf".
+func (f *Float) Inspect() string {
+	switch {
+	case math.IsNaN(f.Value):
+		return "NaN"
+	case math.IsInf(f.Value, 1):
+		return "Inf"
+	case math.IsInf(f.Value, -1):
+		return "-Inf"
+	}
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
 // Boolean rappresenta un valore booleano (true o false).
 type Boolean struct {
 	Value bool // Il valore booleano effettivo.
@@ -49,6 +145,15 @@ type Boolean struct {
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 
+// String rappresenta una stringa di testo.
+type String struct {
+	Value string // Il contenuto della stringa.
+}
+
+// Implementazione dell'interfaccia Object per String.
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
 // Null rappresenta l'assenza di un valore.
 type Null struct{}
 
@@ -66,25 +171,199 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() } // Mostra il valore interno.
 
+// ErrorValue è un errore trattato come un valore qualsiasi: a differenza di Error
+// non interrompe l'esecuzione, ma può essere restituito, controllato con
+// `is_error` o propagato al chiamante con l'operatore postfisso `?`.
+type ErrorValue struct {
+	Message string // La descrizione dell'errore.
+	Data    Object // Dati aggiuntivi a scelta del programma; NULL se assenti.
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ }
+func (ev *ErrorValue) Inspect() string  { return inspect(ev, map[Object]bool{}) }
+
+func (ev *ErrorValue) inspect(visiting map[Object]bool) string {
+	if ev.Data == nil || ev.Data.Type() == NULL_OBJ {
+		return "error(" + strconv.Quote(ev.Message) + ")"
+	}
+	return "error(" + strconv.Quote(ev.Message) + ", " + inspect(ev.Data, visiting) + ")"
+}
+
+// Break è il segnale prodotto da un'istruzione `break`. Come ReturnValue, risale
+// i blocchi annidati fino al ciclo più vicino, che lo consuma e termina.
+type Break struct{}
+
+// Implementazione dell'interfaccia Object per Break.
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue è il segnale prodotto da un'istruzione `continue`: risale fino al ciclo
+// più vicino, che passa all'iterazione successiva.
+type Continue struct{}
+
+// Implementazione dell'interfaccia Object per Continue.
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Frame descrive una chiamata di funzione attiva nel momento in cui è nato un errore.
+type Frame struct {
+	Function string         // Il nome della funzione chiamata ("<anonymous>" se non ne ha uno).
+	Pos      token.Position // Il punto del codice da cui è partita la chiamata.
+}
+
+func (f Frame) String() string {
+	return f.Pos.String() + ", in " + f.Function
+}
+
+// ErrorKind distingue gli errori del programma da quelli che interrompono
+// l'esecuzione per conto dell'host (limiti superati, guasti interni).
+type ErrorKind int
+
+const (
+	RuntimeError       ErrorKind = iota // Un errore del programma Monkey (es. type mismatch).
+	InternalError                       // Un guasto dell'interprete stesso.
+	StepLimitExceeded                   // Superato il numero massimo di passi.
+	DepthLimitExceeded                  // Superata la profondità massima delle chiamate.
+	DeadlineExceeded                    // Scaduto il tempo concesso.
+	Canceled                            // L'host ha annullato l'esecuzione.
+	OutOfMemory                         // Superata la quota di memoria allocata.
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case RuntimeError:
+		return "runtime error"
+	case InternalError:
+		return "internal error"
+	case StepLimitExceeded:
+		return "step limit exceeded"
+	case DepthLimitExceeded:
+		return "depth limit exceeded"
+	case DeadlineExceeded:
+		return "deadline exceeded"
+	case Canceled:
+		return "canceled"
+	case OutOfMemory:
+		return "out of memory"
+	default:
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+}
+
 // Error rappresenta un errore che si verifica durante l'esecuzione del codice.
 type Error struct {
-	Message string // Il messaggio di errore da mostrare.
+	Kind    ErrorKind      // Il tipo di errore; il valore zero è RuntimeError.
+	Message string         // Il messaggio di errore da mostrare.
+	Pos     token.Position // Dove si è verificato l'errore (zero se sconosciuto).
+	End     token.Position // Subito dopo il costrutto che ha causato l'errore.
+	// Trace contiene le chiamate attraversate dall'errore, dalla più interna
+	// alla più esterna, nell'ordine in cui sono state risalite.
+	Trace []Frame
+	// Value è il valore lanciato con 'throw'; nil per gli errori prodotti
+	// dall'interprete.
+	Value Object
 }
 
 // Implementazione dell'interfaccia Object per Error.
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("ERROR: ")
+	if e.Pos.IsValid() {
+		out.WriteString(e.Pos.String() + ": ")
+	}
+	out.WriteString(e.Message)
+
+	if len(e.Trace) > 0 {
+		out.WriteString("\n")
+		out.WriteString(e.Traceback())
+	}
+
+	return out.String()
+}
+
+// errorCodes associa a ogni tipo di errore il codice usato nelle diagnostiche.
+var errorCodes = map[ErrorKind]string{
+	RuntimeError:       "R001",
+	InternalError:      "R002",
+	StepLimitExceeded:  "R003",
+	DepthLimitExceeded: "R004",
+	DeadlineExceeded:   "R005",
+	Canceled:           "R006",
+	OutOfMemory:        "R007",
+}
+
+// Diagnostic converte l'errore in una diagnostica, da presentare con il
+// frammento di sorgente in cui si è verificato. La traccia diventa una nota.
+func (e *Error) Diagnostic() diagnostics.Diagnostic {
+	d := diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Code:     errorCodes[e.Kind],
+		Message:  e.Message,
+		Span:     diagnostics.Span{Start: e.Pos, End: e.End},
+	}
+	if len(e.Trace) > 0 {
+		d.Notes = append(d.Notes, e.Traceback())
+	}
+	return d
+}
+
+// maxTraceRepeat è il numero di frame identici consecutivi mostrati prima di
+// riassumerli: una ricorsione profonda altrimenti riempirebbe lo schermo.
+const maxTraceRepeat = 3
+
+// Traceback formatta la traccia come in Python: la chiamata più recente per ultima.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):")
+
+	repeated := 0
+	for i := len(e.Trace) - 1; i >= 0; i-- {
+		frame := e.Trace[i]
+		if i < len(e.Trace)-1 && frame == e.Trace[i+1] {
+			repeated++
+		} else {
+			flushRepeated(&out, repeated)
+			repeated = 0
+		}
+		if repeated < maxTraceRepeat {
+			out.WriteString("\n  at " + frame.String())
+		}
+	}
+	flushRepeated(&out, repeated)
+
+	return out.String()
+}
+
+// flushRepeated segnala quanti frame identici sono stati omessi.
+func flushRepeated(out *bytes.Buffer, repeated int) {
+	if repeated >= maxTraceRepeat {
+		fmt.Fprintf(out, "\n  [previous frame repeated %d more times]", repeated-maxTraceRepeat+1)
+	}
+}
 
 // Function rappresenta una funzione creata dall'utente nel linguaggio Monkey.
 type Function struct {
 	// I parametri che la funzione accetta.
 	Parameters []*ast.Identifier
+	// I valori predefiniti dei parametri, allineati a Parameters (nil se assente).
+	// Vengono valutati a ogni chiamata, nell'ambiente locale della funzione.
+	Defaults []ast.Expression
+	// Il parametro variadico che raccoglie gli argomenti in eccesso, se presente.
+	Rest *ast.Identifier
 	// Il blocco di codice che viene eseguito quando la funzione è chiamata.
 	Body *ast.BlockStatement
 	// L'ambiente (scope) in cui la funzione è stata definita.
 	// Questo è il segreto delle chiusure (closures): la funzione "ricorda" le variabili
 	// che erano disponibili al momento della sua creazione.
 	Env *Environment
+	// Il nome con cui la funzione è stata definita, se legata con 'let'.
+	Name string
+	// Gli slot del frame, se il programma è stato risolto: a ogni chiamata le
+	// variabili locali vivono in un array invece che in una mappa.
+	Scope *ast.Scope
 }
 
 // Implementazione dell'interfaccia Object per Function.
@@ -93,17 +372,227 @@ func (f *Function) Inspect() string {
 	// Crea una rappresentazione testuale della funzione, es. "fn(x, y) { ... }".
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterList(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+
+// CompiledFunction è una funzione tradotta in bytecode dal compilatore. Vive
+// nel pool delle costanti; a runtime la VM la avvolge in una Closure.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	SourceMap     code.SourceMap
+	NumLocals     int  // Slot locali, parametri compresi
+	NumParameters int  // Parametri con nome, senza quello variadico
+	NumRequired   int  // Parametri che devono ricevere un argomento
+	HasRest       bool // Se vero, lo slot NumParameters riceve gli argomenti in eccesso
+	Name          string
+	LocalNames    []string // I nomi degli slot locali, per i messaggi di errore
+	FreeNames     []string // I nomi delle variabili libere, per i messaggi di errore
+	Source        string   // La forma testuale della funzione, come Function.Inspect
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return cf.Source }
+
+// Closure è una funzione compilata insieme alle celle delle variabili che cattura.
+// Per il programma Monkey è indistinguibile da una Function: ha lo stesso tipo
+// e la stessa rappresentazione.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Source }
+
+// Cell contiene una variabile locale catturata da almeno una chiusura.
+// Value è nil finché la variabile non viene dichiarata con `let`.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell(<unset>)"
+	}
+	return "cell(" + c.Value.Inspect() + ")"
+}
+
+// Array rappresenta una lista ordinata di oggetti, es. [1, "due", fn(x) { x }].
+type Array struct {
+	Elements []Object // Gli elementi contenuti nell'array.
+}
+
+// Implementazione dell'interfaccia Object per Array.
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+func (a *Array) inspect(visiting map[Object]bool) string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, inspect(e, visiting))
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+/*
+inspect rappresenta obj come Inspect, ma si accorge dei cicli: un array, una
+mappa o un errore che contiene (direttamente o no) sé stesso viene mostrato come
+[...], {...} o error(...) la seconda volta che lo si incontra lungo lo stesso
+percorso, invece di ricorrere all'infinito. visiting contiene i contenitori che
+si stanno rappresentando; un valore condiviso ma non ciclico, come in [a, a],
+viene mostrato per intero ogni volta.
+Dentro un contenitore le stringhe sono tra virgolette, con i caratteri speciali
+in forma di escape: così {"1": 1, 1: 2} non si confonde con {1: 1, 1: 2}.
+*/
+func inspect(obj Object, visiting map[Object]bool) string {
+	if s, ok := obj.(*String); ok {
+		return strconv.Quote(s.Value)
+	}
+	container, ok := obj.(interface{ inspect(map[Object]bool) string })
+	if !ok {
+		return obj.Inspect()
+	}
+	if visiting[obj] {
+		switch obj.(type) {
+		case *Array:
+			return "[...]"
+		case *Hash:
+			return "{...}"
+		default:
+			return "error(...)"
+		}
+	}
+	visiting[obj] = true
+	defer delete(visiting, obj)
+	return container.inspect(visiting)
+}
+
+// BuiltinFunction è la firma delle funzioni native messe a disposizione dall'interprete.
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin avvolge una funzione nativa Go in modo che possa essere chiamata dal codice Monkey.
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+// Implementazione dell'interfaccia Object per Builtin.
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function" }
+
+// --- MAPPE (HASH) ---
+
+// HashKey è la chiave con cui un oggetto viene memorizzato in una Hash.
+// Due oggetti con lo stesso tipo e lo stesso valore producono la stessa HashKey.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable è implementata dagli oggetti che possono essere usati come chiavi di una Hash.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+// HashKey per Integer: il valore stesso, reinterpretato come uint64.
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// HashKey per BigInteger: l'hash FNV-1a del segno e delle cifre. Un BigInteger non contiene
+// mai un valore rappresentabile come int64, quindi non può essere uguale a un Integer.
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// HashKey per Boolean: 1 per true, 0 per false.
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// HashKey per String: l'hash FNV-1a a 64 bit del contenuto.
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair conserva la chiave originale insieme al valore, così da poterla mostrare in Inspect.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash rappresenta una mappa chiave/valore. Oltre alle coppie conserva l'ordine di
+// inserimento delle chiavi, in modo che Inspect produca sempre lo stesso risultato.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+// NewHash crea una nuova Hash vuota.
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Get restituisce il valore associato alla chiave, se presente.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// Set associa value alla chiave, aggiungendola in coda se non era già presente.
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Order = append(h.Order, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// Implementazione dell'interfaccia Object per Hash.
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
+
+func (h *Hash) inspect(visiting map[Object]bool) string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range h.Order {
+		pair := h.Pairs[key]
+		pairs = append(pairs, fmt.Sprintf("%s: %s", inspect(pair.Key, visiting), inspect(pair.Value, visiting)))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
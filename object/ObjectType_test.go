@@ -0,0 +1,168 @@
+package object
+
+import (
+	"monkey-interpreter/token"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeysDoNotCollideAcrossTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("integer 1 and true have the same hash key")
+	}
+}
+
+func TestHashPreservesInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "b"}, &Integer{Value: 2})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	if hash.Inspect() != `{"b": 3, "a": 1}` {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+}
+
+func TestInspectQuotesStringsInCollections(t *testing.T) {
+	keys := NewHash()
+	keys.Set(&String{Value: "1"}, NewInteger(1))
+	keys.Set(NewInteger(1), NewInteger(2))
+
+	escaped := NewHash()
+	escaped.Set(&String{Value: "k"}, &String{Value: "v\n"})
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&String{Value: "a\"b"}, `a"b`},
+		{keys, `{"1": 1, 1: 2}`},
+		{&Array{Elements: []Object{&String{Value: `a"b`}}}, `["a\"b"]`},
+		{escaped, `{"k": "v\n"}`},
+		{&ErrorValue{Message: "m", Data: &Array{Elements: []Object{&String{Value: "x"}}}}, `error("m", ["x"])`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestErrorTracebackCollapsesRecursion(t *testing.T) {
+	recursive := Frame{Function: "loop", Pos: token.Position{Line: 2, Column: 3}}
+	err := &Error{
+		Message: "boom",
+		Pos:     token.Position{Line: 2, Column: 10},
+		Trace:   []Frame{recursive, recursive, recursive, recursive, recursive, {Function: "main", Pos: token.Position{Line: 5, Column: 1}}},
+	}
+
+	expected := `ERROR: 2:10: boom
+Traceback (most recent call last):
+  at 5:1, in main
+  at 2:3, in loop
+  at 2:3, in loop
+  at 2:3, in loop
+  [previous frame repeated 2 more times]`
+
+	if err.Inspect() != expected {
+		t.Errorf("wrong Inspect.\nexpected=%s\ngot=%s", expected, err.Inspect())
+	}
+}
+
+func TestEnvironmentSlots(t *testing.T) {
+	global := NewEnvironment()
+	frame := NewFrameEnvironment(global, 2)
+
+	frame.SetSlot(0, 1, &Integer{Value: 7})
+	frame.SetSlot(1, 3, &Integer{Value: 9})
+
+	if got := frame.Slot(0, 1); got == nil || got.Inspect() != "7" {
+		t.Errorf("frame.Slot(0, 1) wrong. got=%v", got)
+	}
+	if got := global.Slot(0, 3); got == nil || got.Inspect() != "9" {
+		t.Errorf("global.Slot(0, 3) wrong. got=%v", got)
+	}
+	if got := frame.Slot(0, 0); got != nil {
+		t.Errorf("unset slot should be nil. got=%v", got)
+	}
+	if got := global.Slot(0, 10); got != nil {
+		t.Errorf("out of range slot should be nil. got=%v", got)
+	}
+}
+
+func TestNewIntegerCache(t *testing.T) {
+	tests := []struct {
+		value  int64
+		shared bool
+	}{
+		{MinCachedInteger, true},
+		{0, true},
+		{MaxCachedInteger, true},
+		{MinCachedInteger - 1, false},
+		{MaxCachedInteger + 1, false},
+	}
+
+	for _, tt := range tests {
+		a, b := NewInteger(tt.value), NewInteger(tt.value)
+		if a.Value != tt.value || b.Value != tt.value {
+			t.Errorf("%d: wrong value. got=%d, %d", tt.value, a.Value, b.Value)
+		}
+		if (a == b) != tt.shared {
+			t.Errorf("%d: expected shared=%t, got %t", tt.value, tt.shared, a == b)
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements[0] = array
+
+	hash := NewHash()
+	hash.Set(&String{Value: "x"}, hash)
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	nested := &Array{Elements: []Object{shared, shared}}
+
+	mixed := &Array{}
+	inner := NewHash()
+	inner.Set(&String{Value: "a"}, mixed)
+	mixed.Elements = []Object{inner, &ErrorValue{Message: "e", Data: mixed}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[[...]]"},
+		{hash, `{"x": {...}}`},
+		{nested, "[[2], [2]]"},
+		{mixed, `[{"a": [...]}, error("e", [...])]`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
@@ -15,9 +15,18 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewFrameEnvironment crea un ambiente figlio con size slot, per le variabili
+// risolte staticamente dal resolver. Non alloca la mappa dei nomi.
+func NewFrameEnvironment(outer *Environment, size int) *Environment {
+	return &Environment{slots: make([]Object, size), outer: outer}
+}
+
 // Environment tiene traccia delle variabili (identificatori e i loro valori).
+// Le variabili di un programma risolto (vedi package resolver) stanno negli
+// slot, indicizzati per posizione; le altre nella mappa, indicizzate per nome.
 type Environment struct {
 	store map[string]Object
+	slots []Object
 	outer *Environment // Puntatore all'ambiente esterno (per le chiusure).
 }
 
@@ -33,6 +42,47 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set aggiunge o aggiorna una variabile nell'ambiente corrente.
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Assign aggiorna una variabile già esistente nello scope in cui è stata dichiarata,
+// risalendo la catena degli ambienti esterni. Restituisce false se la variabile
+// non è dichiarata in nessuno scope.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
+// Slot legge lo slot index dell'ambiente che si trova depth livelli più in
+// fuori. Restituisce nil se la variabile non è ancora stata dichiarata.
+func (e *Environment) Slot(depth, index int) Object {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	if index < len(e.slots) {
+		return e.slots[index]
+	}
+	return nil
+}
+
+// SetSlot scrive lo slot index dell'ambiente che si trova depth livelli più in
+// fuori. Gli slot crescono se serve: l'ambiente globale di un REPL riceve nuove
+// variabili a ogni riga.
+func (e *Environment) SetSlot(depth, index int, val Object) {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	if index >= len(e.slots) {
+		e.slots = append(e.slots, make([]Object, index+1-len(e.slots))...)
+	}
+	e.slots[index] = val
+}
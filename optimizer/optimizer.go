@@ -0,0 +1,405 @@
+// File: optimizer/optimizer.go
+
+/*
+Package optimizer riscrive l'AST di un programma prima dell'esecuzione,
+eliminando il lavoro che si può fare una volta per tutte:
+
+  - le espressioni prefisse e infisse su letterali diventano un letterale
+    (1 + 2 * 3 diventa 7), calcolate con le stesse operazioni dell'evaluator;
+  - di un if con una condizione letterale resta solo il ramo scelto;
+  - le istruzioni che seguono un return in un blocco vengono eliminate;
+  - una funzione letterale chiamata sul posto con argomenti letterali, il cui
+    corpo si riduce a un valore costante, viene sostituita da quel valore.
+
+Il programma ottimizzato dà gli stessi risultati e gli stessi errori, con le
+stesse posizioni: le operazioni che falliscono (es. 1 / 0) non vengono
+calcolate, così che l'errore avvenga a runtime come prima. Cambiano solo i
+passi contati dai limiti di esecuzione.
+*/
+package optimizer
+
+import (
+	"math/big"
+	"monkey-interpreter/ast"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/object"
+	"monkey-interpreter/token"
+	"strconv"
+)
+
+// maxFoldedShift è il massimo esponente (per "**") o spostamento (per "<<")
+// calcolato in anticipo: oltre, il risultato potrebbe essere enorme e il calcolo
+// è lasciato all'esecuzione, dove è soggetto alla quota di memoria.
+const maxFoldedShift = 1024
+
+// Optimize riscrive program sul posto e lo restituisce.
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements, false)
+	return program
+}
+
+// statements ottimizza una sequenza di istruzioni. Un if con una condizione
+// letterale usato come istruzione viene sostituito dalle istruzioni del ramo
+// scelto: i blocchi non hanno uno scope proprio, quindi il significato non
+// cambia. Se block è vero, le istruzioni dopo un return vengono scartate.
+func statements(list []ast.Statement, block bool) []ast.Statement {
+	out := make([]ast.Statement, 0, len(list))
+	for i, s := range list {
+		s = statement(s)
+
+		if branch, ok := chosenBranch(s); ok {
+			switch {
+			case branch != nil && len(branch.Statements) > 0:
+				out = append(out, branch.Statements...)
+				s = nil
+			case i < len(list)-1:
+				// Un ramo vuoto vale null, che qui viene comunque scartato.
+				s = nil
+			}
+		}
+		if s != nil {
+			out = append(out, s)
+		}
+
+		// I rami sono già stati ottimizzati: un return può essere solo in fondo.
+		if block && len(out) > 0 {
+			if _, ok := out[len(out)-1].(*ast.ReturnStatement); ok {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// chosenBranch riconosce un'istruzione formata da un if con una condizione
+// letterale e ne restituisce il ramo che verrebbe eseguito (nil se nessuno).
+func chosenBranch(s ast.Statement) (*ast.BlockStatement, bool) {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	value, ok := literalValue(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+	if evaluator.IsTruthy(value) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+func statement(s ast.Statement) ast.Statement {
+	if ast.IsNil(s) {
+		return s
+	}
+
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		s.Expression = expression(s.Expression)
+	case *ast.LetStatement:
+		s.Value = expression(s.Value)
+	case *ast.ReturnStatement:
+		s.ReturnValue = expression(s.ReturnValue)
+	case *ast.ThrowStatement:
+		s.Value = expression(s.Value)
+	case *ast.BlockStatement:
+		blockStatement(s)
+	case *ast.WhileStatement:
+		s.Condition = expression(s.Condition)
+		blockStatement(s.Body)
+	case *ast.ForStatement:
+		s.Init = statement(s.Init)
+		s.Condition = expression(s.Condition)
+		s.Update = statement(s.Update)
+		blockStatement(s.Body)
+	}
+	return s
+}
+
+func blockStatement(b *ast.BlockStatement) {
+	if b != nil {
+		b.Statements = statements(b.Statements, true)
+	}
+}
+
+func expression(e ast.Expression) ast.Expression {
+	if ast.IsNil(e) {
+		return e
+	}
+
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = expression(e.Right)
+		return foldPrefix(e)
+	case *ast.InfixExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+		return foldInfix(e)
+	case *ast.PostfixExpression:
+		e.Left = expression(e.Left)
+	case *ast.LogicalExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+	case *ast.AssignExpression:
+		if index, ok := e.Target.(*ast.IndexExpression); ok {
+			index.Left = operand(index.Left)
+			index.Index = expression(index.Index)
+		}
+		e.Value = expression(e.Value)
+	case *ast.IfExpression:
+		return ifExpression(e)
+	case *ast.TryExpression:
+		blockStatement(e.Block)
+		blockStatement(e.Catch)
+		blockStatement(e.Finally)
+	case *ast.FunctionLiteral:
+		for i, d := range e.Defaults {
+			e.Defaults[i] = expression(d)
+		}
+		blockStatement(e.Body)
+	case *ast.CallExpression:
+		e.Function = operand(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = expression(arg)
+		}
+		return inline(e)
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = expression(el)
+		}
+	case *ast.IndexExpression:
+		e.Left = operand(e.Left)
+		e.Index = expression(e.Index)
+	case *ast.HashLiteral:
+		for _, pair := range e.Pairs {
+			pair.Key = expression(pair.Key)
+			pair.Value = expression(pair.Value)
+		}
+	}
+	return e
+}
+
+// operand ottimizza l'operando sinistro di un indice o di una chiamata. Gli errori
+// di queste espressioni sono riportati alla posizione dell'operando: un if resta
+// al suo posto, anche se ridotto al ramo scelto, a meno di diventare un letterale,
+// che prende la posizione dell'if.
+func operand(e ast.Expression) ast.Expression {
+	ie, ok := e.(*ast.IfExpression)
+	if !ok {
+		return expression(e)
+	}
+	result := ifExpression(ie)
+	if _, ok := literalValue(result); ok {
+		return result
+	}
+	return ie
+}
+
+// ifExpression ottimizza un if usato come espressione. Con una condizione
+// letterale, il ramo scartato viene eliminato; se il ramo scelto è una sola
+// espressione, l'if viene sostituito da quella. Un letterale prende lo span
+// dell'if, così che gli errori in cui compare restino alla stessa posizione.
+func ifExpression(ie *ast.IfExpression) ast.Expression {
+	ie.Condition = expression(ie.Condition)
+	blockStatement(ie.Consequence)
+	blockStatement(ie.Alternative)
+
+	value, ok := literalValue(ie.Condition)
+	if !ok {
+		return ie
+	}
+	branch := ie.Alternative
+	if evaluator.IsTruthy(value) {
+		branch = ie.Consequence
+		ie.Alternative = nil
+	} else if ie.Consequence != nil {
+		ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token}
+	}
+
+	if branch != nil && len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && !ast.IsNil(es.Expression) {
+			if value, ok := literalValue(es.Expression); ok {
+				return literalOr(value, ie)
+			}
+			return es.Expression
+		}
+	}
+	return ie
+}
+
+// foldPrefix calcola un operatore prefisso applicato a un letterale.
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	right, ok := literalValue(pe.Right)
+	if !ok {
+		return pe
+	}
+	return literalOr(evaluator.PrefixOperation(pe.Operator, right), pe)
+}
+
+// foldInfix calcola un operatore infisso applicato a due letterali.
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	left, ok := literalValue(ie.Left)
+	if !ok {
+		return ie
+	}
+	right, ok := literalValue(ie.Right)
+	if !ok {
+		return ie
+	}
+	if (ie.Operator == "**" || ie.Operator == "<<") && !smallExponent(left, right) {
+		return ie
+	}
+	return literalOr(evaluator.InfixOperation(ie.Operator, left, right), ie)
+}
+
+// smallExponent dice se base ** exponent (o base << exponent) ha una dimensione
+// ragionevole da calcolare in anticipo.
+func smallExponent(base, exponent object.Object) bool {
+	e, ok := exponent.(*object.Integer)
+	if !ok || e.Value > maxFoldedShift {
+		return false
+	}
+	_, big := base.(*object.BigInteger)
+	return !big
+}
+
+/*
+inline sostituisce la chiamata di una funzione letterale con il valore che
+restituisce, se lo si può calcolare subito: la funzione non ha parametri
+predefiniti né variadici, riceve un argomento letterale per ogni parametro, e il
+suo corpo è una sola espressione che, sostituiti i parametri, si riduce a un
+letterale. In ogni altro caso (variabili libere, chiamate, operazioni che
+falliscono) la chiamata resta com'è.
+*/
+func inline(call *ast.CallExpression) ast.Expression {
+	fn, ok := call.Function.(*ast.FunctionLiteral)
+	if !ok || fn.Rest != nil || fn.Body == nil || len(fn.Body.Statements) != 1 || len(call.Arguments) != len(fn.Parameters) {
+		return call
+	}
+	for _, d := range fn.Defaults {
+		if !ast.IsNil(d) {
+			return call
+		}
+	}
+
+	params := make(map[string]ast.Expression, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		if _, ok := literalValue(call.Arguments[i]); !ok {
+			return call
+		}
+		params[p.Value] = call.Arguments[i]
+	}
+
+	var body ast.Expression
+	switch s := fn.Body.Statements[0].(type) {
+	case *ast.ExpressionStatement:
+		body = s.Expression
+	case *ast.ReturnStatement:
+		body = s.ReturnValue
+	default:
+		return call
+	}
+
+	folded, ok := substitute(body, params)
+	if !ok {
+		return call
+	}
+	value, ok := literalValue(folded)
+	if !ok {
+		return call
+	}
+	return literal(value, call.Pos(), call.End())
+}
+
+// substitute ricalcola expr con i parametri sostituiti dai loro argomenti. Non
+// modifica expr; fallisce se expr contiene altro che letterali, parametri e
+// operatori.
+func substitute(expr ast.Expression, params map[string]ast.Expression) (ast.Expression, bool) {
+	if ast.IsNil(expr) {
+		return nil, false
+	}
+
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return e, true
+	case *ast.Identifier:
+		arg, ok := params[e.Value]
+		return arg, ok
+	case *ast.PrefixExpression:
+		right, ok := substitute(e.Right, params)
+		if !ok {
+			return nil, false
+		}
+		return foldPrefix(&ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: right}), true
+	case *ast.InfixExpression:
+		left, ok := substitute(e.Left, params)
+		if !ok {
+			return nil, false
+		}
+		right, ok := substitute(e.Right, params)
+		if !ok {
+			return nil, false
+		}
+		return foldInfix(&ast.InfixExpression{Token: e.Token, Left: left, Operator: e.Operator, Right: right}), true
+	}
+	return nil, false
+}
+
+// literalValue restituisce il valore di un'espressione letterale.
+func literalValue(e ast.Expression) (object.Object, bool) {
+	if ast.IsNil(e) {
+		return nil, false
+	}
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		if e.Big != nil {
+			return object.IntegerFromBig(e.Big), true
+		}
+		return object.NewInteger(e.Value), true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: e.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: e.Value}, true
+	case *ast.Boolean:
+		if e.Value {
+			return evaluator.TRUE, true
+		}
+		return evaluator.FALSE, true
+	}
+	return nil, false
+}
+
+// literalOr restituisce il letterale che rappresenta value, al posto di node;
+// se value è un errore o non ha un letterale, restituisce node.
+func literalOr(value object.Object, node ast.Expression) ast.Expression {
+	if lit := literal(value, node.Pos(), node.End()); lit != nil {
+		return lit
+	}
+	return node
+}
+
+// literal costruisce un letterale per value, che occupa il sorgente da pos a end.
+func literal(value object.Object, pos, end token.Position) ast.Expression {
+	switch v := value.(type) {
+	case *object.Integer:
+		lit := strconv.FormatInt(v.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit, Pos: pos, End: end}, Value: v.Value}
+	case *object.BigInteger:
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: v.Value.String(), Pos: pos, End: end}, Big: new(big.Int).Set(v.Value)}
+	case *object.Float:
+		return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: v.Inspect(), Pos: pos, End: end}, Value: v.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: v.Value, Pos: pos, End: end}, Value: v.Value}
+	case *object.Boolean:
+		tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos, End: end}
+		if v.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: v.Value}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// optimizer/optimizer_test.go
+package optimizer
+
+import (
+	"monkey-interpreter/ast"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+// describe riassume un risultato per il confronto: per un errore include
+// posizione e traccia, oltre al messaggio.
+func describe(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return err.Inspect() + "\n" + err.Traceback()
+	}
+	return obj.Inspect()
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Espressioni costanti
+		{"1 + 2 * 3", "7"},
+		{"-(2 - 5)", "3"},
+		{"!true", "false"},
+		{"~0", "-1"},
+		{"1.5 * 2", "3.0"},
+		{`"mon" + "key"`, `"monkey"`},
+		{"1 < 2", "true"},
+		{"2 ** 10", "1024"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"x + 1 * 2", "(x + 2)"},
+		// Le operazioni che falliscono restano per l'esecuzione.
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"(1 / 0) + 2", "((1 / 0) + 2)"},
+		{"2 ** 100000", "(2 ** 100000)"},
+
+		// If con condizioni letterali
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{"if (false) { 10 } else { 20 }", "20"},
+		{"if (true) { let a = 1; a }", "let a = 1;a"},
+		{"if (false) { 10 }; 5", "5"},
+		{"let x = if (true) { 1 } else { 2 }", "let x = 1;"},
+		{"if (x) { 1 } else { 2 }", "ifx 1else 2"},
+
+		// Codice irraggiungibile
+		{"fn() { return 1; puts(2); 3 }", "fn() return 1;"},
+		{"fn() { if (true) { return 1 } 2 }", "fn() return 1;"},
+		{"fn() { while (x) { return 1; 2 } 3 }", "fn() whilex return 1;3"},
+
+		// Funzioni chiamate sul posto
+		{"fn(a, b) { a * b + 1 }(2, 3)", "7"},
+		{"fn() { return 40 + 2 }()", "42"},
+		{"fn(a) { a + x }(1)", "fn(a) (a + x)(1)"},
+		{"fn(a) { a / 0 }(1)", "fn(a) (a / 0)(1)"},
+		{"fn(a) { a }(x)", "fn(a) a(x)"},
+		{"fn(a, b = 1) { a + b }(1)", "fn(a, b = 1) (a + b)(1)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		got := Optimize(program).String()
+		if got != tt.expected {
+			t.Errorf("%q: wrong program.\nwant=%q\ngot= %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestSemanticsPreserved esegue gli stessi programmi con e senza ottimizzazione:
+// risultati ed errori devono coincidere, posizioni e tracce comprese.
+func TestSemanticsPreserved(t *testing.T) {
+	tests := []string{
+		"1 + 2 * 3 - 4 / 2",
+		"1 / 0",
+		"1 + 2 + true",
+		"-true",
+		"10 % 0 + 1",
+		"let x = 5; if (1 > 2) { x = 1 } else { x = 2 }; x",
+		"let x = 5; if (true) { let x = 2 }; x",
+		"if (false) { 1 }",
+		"if (0) { \"zero is truthy\" } else { \"no\" }",
+		"let f = fn(n) { if (true) { return n * 2; } n }; f(4)",
+		"let f = fn() { return 1; missing }; f()",
+		"let i = 0; while (i < 10) { i += 1; if (false) { break } }; i",
+		"let i = 0; while (true) { i += 1; if (i > 3) { break } }; i",
+		"fn(a, b) { a * b + 1 }(2, 3)",
+		"fn(a) { a + true }(1)",
+		"fn(a) { a / 0 }(1)",
+		"let k = 3; fn(a) { a + k }(1)",
+		"let f = fn(x) { x + (2 * 3) }; f(1) + f(2)",
+		"try { 1 / (2 - 2) } catch (e) { e[\"message\"] }",
+		"[1 + 1, {\"a\" + \"b\": 2 ** 3}]",
+		"let s = 0; for (let i = 0; i < 3; i += 1) { if (true) { s += i } }; s",
+		"2 ** 100",
+		// Gli errori di indici e chiamate sono riportati alla posizione dell'operando.
+		"let c = 1; let a = if (true) { 110 } else { 1 }[(c - 9.61)]",
+		"let c = 1; if (true) { c } else { 1 }[0]",
+		"let c = 1; if (false) { 1 } else { c }(0)",
+		"let c = [1]; (if (true) { c } else { 1 })[0][1]",
+		"let c = [1]; if (true) { c } else { 1 }[0] = true + 1",
+		"if (true) { 1 } else { 2 }(0)",
+	}
+
+	for _, input := range tests {
+		want := describe(evaluator.Run(parse(input), object.NewEnvironment()))
+		got := describe(evaluator.Run(Optimize(parse(input)), object.NewEnvironment()))
+		if got != want {
+			t.Errorf("%q:\noptimized: %s\noriginal:  %s", input, got, want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey-interpreter/ast"
+	"monkey-interpreter/diagnostics"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/token"
 	"strconv"
@@ -13,36 +16,86 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = += -= *= /= %= (associativo a destra)
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
 	EQUALS      // ==
 	LESSGREATER // > or <
+	SHIFT       // << or >>
 	SUM         // + or -
-	PRODUCT     // * or /
-	PREFIX      // -X or !X
+	PRODUCT     // * or / or %
+	PREFIX      // -X or !X or ~X
+	POWER       // X ** Y (associativo a destra)
 	CALL        // myFunction(X)
+	INDEX       // array[index]
+	POSTFIX     // value?
 )
 
 // Mappa che associa i token degli operatori con la loro precedenza
 var precedences = map[token.TokenType]int{
-	token.LPAREN:       CALL,
-	token.EQ:           EQUALS,
-	token.NOT_EQ:       EQUALS,
-	token.LT:           LESSGREATER,
-	token.GT:           LESSGREATER,
-	token.PLUS:         SUM,
-	token.DASH:         SUM,
-	token.FORWARDSLASH: PRODUCT,
-	token.STAR:         PRODUCT,
+	token.ASSIGN:         ASSIGN,
+	token.PLUS_ASSIGN:    ASSIGN,
+	token.MINUS_ASSIGN:   ASSIGN,
+	token.STAR_ASSIGN:    ASSIGN,
+	token.SLASH_ASSIGN:   ASSIGN,
+	token.PERCENT_ASSIGN: ASSIGN,
+	token.LPAREN:         CALL,
+	token.LBRACKET:       INDEX,
+	token.QUESTION:       POSTFIX,
+	token.OR:             LOGICAL_OR,
+	token.AND:            LOGICAL_AND,
+	token.PIPE:           BIT_OR,
+	token.CARET:          BIT_XOR,
+	token.AMPERSAND:      BIT_AND,
+	token.EQ:             EQUALS,
+	token.NOT_EQ:         EQUALS,
+	token.LT:             LESSGREATER,
+	token.GT:             LESSGREATER,
+	token.LT_EQ:          LESSGREATER,
+	token.GT_EQ:          LESSGREATER,
+	token.SHIFT_LEFT:     SHIFT,
+	token.SHIFT_RIGHT:    SHIFT,
+	token.PLUS:           SUM,
+	token.DASH:           SUM,
+	token.FORWARDSLASH:   PRODUCT,
+	token.STAR:           PRODUCT,
+	token.PERCENT:        PRODUCT,
+	token.POWER:          POWER,
 }
 
 // Parser è la struttura che rappresenta il parser del linguaggio Monkey.
 // È responsabile di analizzare il flusso di token generati dal lexer e costruire l'AST.
 type Parser struct {
 	l              *lexer.Lexer
-	errors         []string
+	diagnostics    []diagnostics.Diagnostic
 	curToken       token.Token
 	peekToken      token.Token
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// comments raccoglie i commenti (trivia) dei token letti, per ast.Program.Comments
+	comments []token.Token
+
+	// loopDepth conta i cicli che racchiudono il punto corrente, per rifiutare
+	// 'break' e 'continue' fuori da un ciclo. Torna a zero dentro le funzioni.
+	loopDepth int
+
+	// nesting conta le parentesi ( [ { aperte fino a curToken compreso.
+	nesting int
+
+	// recovering è vero dopo un errore, finché il parser non si risincronizza:
+	// in quel tratto gli errori sono quasi sempre conseguenze del primo e
+	// vengono scartati.
+	recovering bool
+
+	// consumed conta i token diventati curToken; stray e strayAt ricordano
+	// l'ultimo token fuori posto segnalato e la sua posizione in questo conteggio.
+	consumed int
+	stray    token.TokenType
+	strayAt  int
 }
 
 type (
@@ -54,7 +107,6 @@ type (
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
@@ -62,11 +114,25 @@ func New(l *lexer.Lexer) *Parser {
 	// Registriamo la funzione di parsing per call expression
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 
+	// Registriamo il parsing degli array letterali e dell'accesso tramite indice
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
+	// Registriamo il parsing delle mappe letterali. Una '{' in posizione di espressione
+	// apre sempre una mappa: i blocchi di istruzioni sono analizzati direttamente da
+	// parseBlockStatement dopo 'if', 'else' e 'fn', quindi non c'è ambiguità.
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
 	// Registriamo la funzione di parsing per function literal
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
 	// Registriamo la funzione di parsing per il token IF
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+
+	// L'operatore postfisso '?' si registra come infisso: segue un'espressione
+	// già analizzata, ma non ha un operando destro.
+	p.registerInfix(token.QUESTION, p.parsePostfixExpression)
 
 	// Registriamo il parsing delle parentesi
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -78,8 +144,11 @@ func New(l *lexer.Lexer) *Parser {
 	// Registrazione dei parser per i prefissi (es. identificatori, interi, operatori prefissi)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.DASH, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 
 	// Registrazione dei parser per gli operatori infissi (es. +, -, *, /)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -90,6 +159,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+
+	// Registrazione degli assegnamenti (semplici e composti)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.STAR_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
+
+	// Gli operatori logici hanno un nodo dedicato perché sono cortocircuitati
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
 
 	// Avanza per impostare curToken e peekToken
 	p.nextToken()
@@ -103,52 +193,194 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
-// Errors restituisce gli errori incontrati durante il parsing.
+// Codici delle diagnostiche prodotte dal parser.
+const (
+	CodeUnexpectedToken   = "P001" // Manca un token atteso
+	CodeExpectedExpr      = "P002" // Manca un'espressione
+	CodeInvalidNumber     = "P003" // Letterale numerico non valido
+	CodeInvalidAssignment = "P004" // Lato sinistro di un assegnamento non valido
+	CodeOutsideLoop       = "P005" // 'break' o 'continue' fuori da un ciclo
+	CodeInvalidParameters = "P006" // Lista di parametri non valida
+	CodeInvalidToken      = "P007" // Token non valido per un motivo noto, es. una stringa non terminata
+)
+
+// Errors restituisce gli errori incontrati durante il parsing, uno per riga nel
+// formato "file:riga:colonna: messaggio".
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.String())
+	}
+	return errors
+}
+
+// Diagnostics restituisce gli errori incontrati durante il parsing in forma
+// strutturata, da presentare con il pacchetto diagnostics.
+func (p *Parser) Diagnostics() []diagnostics.Diagnostic {
+	return p.diagnostics
+}
+
+// errorAt registra un errore relativo a span. Mentre il parser si sta riprendendo
+// da un errore precedente, il nuovo errore viene ignorato e errorAt restituisce nil;
+// altrimenti restituisce la diagnostica appena aggiunta, a cui il chiamante può
+// aggiungere etichette o una correzione.
+func (p *Parser) errorAt(code string, span diagnostics.Span, format string, a ...interface{}) *diagnostics.Diagnostic {
+	if p.recovering {
+		return nil
+	}
+	p.recovering = true
+
+	p.diagnostics = append(p.diagnostics, diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	})
+	return &p.diagnostics[len(p.diagnostics)-1]
 }
 
-// peekError aggiunge un errore alla lista se il token successivo non è quello atteso.
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+// nodeSpan restituisce lo span occupato da un nodo dell'AST.
+func nodeSpan(node ast.Node) diagnostics.Span {
+	return diagnostics.Span{Start: node.Pos(), End: node.End()}
 }
 
 // nextToken avanza il parser al prossimo token.
 func (p *Parser) nextToken() {
+	p.consumed++
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.comments = append(p.comments, p.peekToken.Trivia...)
+
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		p.nesting++
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if p.nesting > 0 {
+			p.nesting--
+		}
+	}
 }
 
-// ParseProgram crea un AST per il programma analizzando una lista di dichiarazioni.
-func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
+/*
+synchronize fa ripartire il parser dopo un errore ("panic mode"): scarta i token
+fino alla fine dell'istruzione rotta, cioè fino a un ';', oppure fino a prima di
+una parola chiave che apre un'istruzione o della '}' del blocco che la contiene.
+depth è il livello di parentesi all'inizio dell'istruzione: i punti di
+sincronizzazione dentro parentesi aperte dall'istruzione stessa non contano.
+*/
+func (p *Parser) synchronize(depth int) {
+	for !p.curTokenIs(token.EOF) && !p.peekTokenIs(token.EOF) && p.nesting >= depth {
+		if p.nesting == depth && (p.curTokenIs(token.SEMICOLON) ||
+			p.peekTokenIs(token.RBRACE) || startsStatement(p.peekToken.Type)) {
+			break
+		}
+		p.nextToken()
+	}
+	p.recovering = false
+}
 
-	// Continua a parsare finché non si raggiunge la fine dell'input
-	for p.curToken.Type != token.EOF {
+// startsStatement verifica se un token apre sempre una nuova istruzione.
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.THROW:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseStatementList analizza istruzioni fino a un token di chiusura (la '}' di un
+// blocco o la fine dell'input), riprendendosi dagli errori tra un'istruzione e l'altra.
+// Le istruzioni con errori non vengono aggiunte: l'AST resta privo di nodi incompleti.
+func (p *Parser) parseStatementList(end token.TokenType) []ast.Statement {
+	statements := []ast.Statement{}
+
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) {
+		// nesting conta già curToken: se l'istruzione si apre con una parentesi,
+		// quella appartiene all'istruzione e non al livello in cui si trova.
+		depth := p.nesting
+		if p.curTokenIs(token.LPAREN) || p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+			depth--
+		}
 		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+
+		if p.recovering {
+			p.synchronize(depth)
+			if p.nesting < depth {
+				// L'istruzione rotta ha consumato anche la '}' che chiude il blocco.
+				break
+			}
+		} else if stmt != nil {
+			statements = append(statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	return statements
+}
+
+// describeToken descrive un token trovato, per i messaggi di errore.
+func describeToken(tok token.Token) string {
+	switch tok.Type {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "identifier `" + tok.Literal + "`"
+	case token.INT, token.FLOAT:
+		return "number `" + tok.Literal + "`"
+	case token.STRING:
+		return "string " + strconv.Quote(tok.Literal)
+	case token.ILLEGAL:
+		if tok.Problem != "" {
+			return tok.Problem
+		}
+		return "invalid token `" + tok.Literal + "`"
+	default:
+		return "`" + tok.Literal + "`"
+	}
+}
+
+// describeType descrive un tipo di token atteso, per i messaggi di errore.
+func describeType(t token.TokenType) string {
+	switch t {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "identifier"
+	case token.INT, token.FLOAT:
+		return "number"
+	case token.STRING:
+		return "string"
+	default:
+		// Per delimitatori e operatori il tipo coincide con il testo del token.
+		return "`" + string(t) + "`"
+	}
+}
+
+// ParseProgram crea un AST per il programma analizzando una lista di dichiarazioni.
+func (p *Parser) ParseProgram() *ast.Program {
+	program := &ast.Program{}
+
+	// Continua a parsare finché non si raggiunge la fine dell'input
+	program.Statements = p.parseStatementList(token.EOF)
+
+	program.Comments = p.comments
 	return program
 }
 
 // parseLetStatement analizza una dichiarazione let, ad esempio "let x = 5;".
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+// In caso di errore restituisce un nil "vero" (non un *ast.LetStatement nil),
+// così che ParseProgram non lo aggiunga al programma.
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
+	if !p.expectPeekAfter(token.IDENT, "`let`") {
 		return nil
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	if !p.expectPeek(token.ASSIGN) {
+	if !p.expectPeekAfter(token.ASSIGN, "`let "+stmt.Name.Value+"`") {
 		return nil
 	}
 
@@ -156,6 +388,12 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	// Una funzione legata con 'let' prende il nome della variabile: ci serve
+	// per mostrare tracce di esecuzione leggibili.
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -173,14 +411,65 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
-// expectPeek verifica se il prossimo token è quello atteso e avanza il parser.
-func (p *Parser) expectPeek(t token.TokenType) bool {
+// expectPeekAfter verifica se il prossimo token è quello atteso e avanza il parser.
+// Altrimenti registra un errore che dice anche dopo cosa era atteso il token,
+// es. "expected `)` after if condition".
+func (p *Parser) expectPeekAfter(t token.TokenType, after string) bool {
 	if p.peekTokenIs(t) {
 		p.nextToken()
 		return true
-	} else {
-		p.peekError(t)
-		return false
+	}
+	p.missingToken(t, after)
+	return false
+}
+
+// expectClosing è come expectPeekAfter per una parentesi di chiusura: se manca,
+// l'errore indica anche la parentesi open rimasta aperta e suggerisce di chiuderla.
+func (p *Parser) expectClosing(t token.TokenType, after string, open token.Token) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+	p.unclosed(p.missingToken(t, after), open, t)
+	return false
+}
+
+// missingToken segnala che dopo curToken era atteso un token di tipo t.
+func (p *Parser) missingToken(t token.TokenType, after string) *diagnostics.Diagnostic {
+	return p.errorAt(CodeUnexpectedToken, diagnostics.SpanOf(p.peekToken),
+		"expected %s after %s, got %s", describeType(t), after, describeToken(p.peekToken))
+}
+
+// unclosed completa la diagnostica d (se non è stata scartata) con la posizione
+// della parentesi open e la correzione che inserisce la chiusura dopo curToken.
+func (p *Parser) unclosed(d *diagnostics.Diagnostic, open token.Token, closing token.TokenType) {
+	if d == nil {
+		return
+	}
+	d.Labels = append(d.Labels, diagnostics.Label{
+		Span:    diagnostics.SpanOf(open),
+		Message: fmt.Sprintf("to match this `%s`", open.Literal),
+	})
+	d.Fix = p.insertAfterCurrent(string(closing))
+}
+
+// unclosedList è come unclosed per una lista (argomenti, elementi, coppie di una
+// mappa) a cui manca sia la `,` sia la chiusura: se il token successivo può
+// iniziare un altro elemento, la correzione più probabile è inserire la virgola.
+func (p *Parser) unclosedList(d *diagnostics.Diagnostic, open token.Token, closing token.TokenType) {
+	p.unclosed(d, open, closing)
+	if d != nil && p.prefixParseFns[p.peekToken.Type] != nil {
+		d.Fix = p.insertAfterCurrent(",")
+	}
+}
+
+// insertAfterCurrent costruisce la correzione che inserisce text subito dopo curToken.
+func (p *Parser) insertAfterCurrent(text string) *diagnostics.Fix {
+	end := p.curToken.End
+	return &diagnostics.Fix{
+		Message:     fmt.Sprintf("insert `%s`", text),
+		Span:        diagnostics.Span{Start: end, End: end},
+		Replacement: text,
 	}
 }
 
@@ -191,6 +480,16 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -232,17 +531,28 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-// noPrefixParseFnError registra un errore quando non esiste una funzione di parsing per un prefisso.
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+// noPrefixParseFnError registra un errore quando il token corrente non può iniziare un'espressione.
+// Una serie di token uguali fuori posto (es. "}}}") produce un solo errore: per quelli che
+// seguono subito il primo il parser si limita a entrare in recupero.
+func (p *Parser) noPrefixParseFnError() {
+	repeated := p.strayAt > 0 && p.consumed == p.strayAt+1 && p.curTokenIs(p.stray)
+	p.stray, p.strayAt = p.curToken.Type, p.consumed
+	if repeated {
+		p.recovering = true
+		return
+	}
+	if p.curTokenIs(token.ILLEGAL) && p.curToken.Problem != "" {
+		p.errorAt(CodeInvalidToken, diagnostics.SpanOf(p.curToken), "%s", p.curToken.Problem)
+		return
+	}
+	p.errorAt(CodeExpectedExpr, diagnostics.SpanOf(p.curToken), "expected expression, got %s", describeToken(p.curToken))
 }
 
 // parseExpression gestisce il parsing delle espressioni, scegliendo tra operatori prefissi o infissi.
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError()
 		return nil
 	}
 
@@ -262,14 +572,34 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-// parseIntegerLiteral gestisce il parsing di un valore intero.
+// parseIntegerLiteral gestisce il parsing di un valore intero. I letterali troppo grandi
+// per un int64 vengono conservati come *big.Int.
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = bigValue
+			return lit
+		}
+	}
+	if err != nil {
+		p.errorAt(CodeInvalidNumber, diagnostics.SpanOf(p.curToken), "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+// parseFloatLiteral gestisce il parsing di un numero in virgola mobile.
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(CodeInvalidNumber, diagnostics.SpanOf(p.curToken), "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -277,6 +607,11 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// parseStringLiteral crea un nodo AST per una stringa letterale.
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 // parsePrefixExpression gestisce il parsing di un operatore prefisso (es. "!5", "-10").
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
@@ -314,13 +649,67 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left, // L'espressione già parsata (come il numero `1`).
 	}
 
-	precedence := p.curPrecedence()                  // Salva la precedenza dell'operatore corrente.
+	precedence := p.curPrecedence() // Salva la precedenza dell'operatore corrente.
+	if p.curTokenIs(token.POWER) {
+		// "**" è associativo a destra: 2 ** 3 ** 2 equivale a 2 ** (3 ** 2).
+		precedence--
+	}
 	p.nextToken()                                    // Avanza al prossimo token (es. `2`).
 	expression.Right = p.parseExpression(precedence) // Continua il parsing per il lato destro.
 
 	return expression
 }
 
+// parseAssignExpression gestisce il parsing di un assegnamento, es. "x = 5" o "arr[i] += 1".
+// Il lato sinistro deve essere un identificatore o un'espressione di indice.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		d := p.errorAt(CodeInvalidAssignment, nodeSpan(target), "invalid assignment target: %s", target)
+		if d != nil && p.curTokenIs(token.ASSIGN) {
+			d.Fix = &diagnostics.Fix{
+				Message:     "did you mean `==`?",
+				Span:        diagnostics.SpanOf(p.curToken),
+				Replacement: "==",
+			}
+		}
+		return nil
+	}
+
+	// Associativo a destra: a = b = 1 equivale ad a = (b = 1).
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+// parsePostfixExpression crea il nodo per un operatore postfisso, es. "f()?".
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{Token: p.curToken, Left: left, Operator: p.curToken.Literal}
+}
+
+// parseLogicalExpression gestisce il parsing degli operatori logici "&&" e "||".
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
 var traceLevel int = 0
 
 func trace(msg string) string {
@@ -340,11 +729,12 @@ func (p *Parser) parseBoolean() ast.Expression {
 
 // parseGroupedExpression analizza le espressioni racchiuse tra parentesi
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	open := p.curToken
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(token.RPAREN, "parenthesized expression", open) {
 		return nil
 	}
 
@@ -354,18 +744,19 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
-	if !p.expectPeek(token.LPAREN) {
+	if !p.expectPeekAfter(token.LPAREN, "`if`") {
 		return nil
 	}
+	open := p.curToken
 
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(token.RPAREN, "if condition", open) {
 		return nil
 	}
 
-	if !p.expectPeek(token.LBRACE) {
+	if !p.expectPeekAfter(token.LBRACE, "`if (...)`") {
 		return nil
 	}
 
@@ -374,7 +765,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
-		if !p.expectPeek(token.LBRACE) {
+		if !p.expectPeekAfter(token.LBRACE, "`else`") {
 			return nil
 		}
 
@@ -387,16 +778,13 @@ func (p *Parser) parseIfExpression() ast.Expression {
 // parseBlockStatement analizza un blocco di istruzioni
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
-	block.Statements = []ast.Statement{}
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
-		p.nextToken()
+	block.Statements = p.parseStatementList(token.RBRACE)
+
+	if p.curTokenIs(token.RBRACE) {
+		block.RBrace = p.curToken
 	}
 
 	return block
@@ -405,74 +793,374 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
-	if !p.expectPeek(token.LPAREN) {
+	if !p.expectPeekAfter(token.LPAREN, "`fn`") {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
-	if !p.expectPeek(token.LBRACE) {
+	if !p.expectPeekAfter(token.LBRACE, "parameter list") {
 		return nil
 	}
 
+	// Il corpo di una funzione non eredita i cicli in cui la funzione è definita.
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = outerLoopDepth
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+/*
+parseFunctionParameters legge i parametri di lit fino alla ')' di chiusura.
+Un parametro può avere un valore predefinito ("y = 10"); dopo il primo valore
+predefinito tutti i parametri successivi devono averne uno. L'ultimo parametro
+può essere variadico ("...rest") e raccoglie gli argomenti in eccesso.
+*/
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
+	seen := map[string]bool{}
+	hasDefaults := false
+
+	for {
+		p.nextToken()
+
+		rest := p.curTokenIs(token.ELLIPSIS)
+		if rest && !p.expectPeekAfter(token.IDENT, "`...`") {
+			return false
+		}
+		if !p.curTokenIs(token.IDENT) {
+			p.errorAt(CodeInvalidParameters, diagnostics.SpanOf(p.curToken), "expected parameter name, got %s", describeToken(p.curToken))
+			return false
+		}
+
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[ident.Value] {
+			p.errorAt(CodeInvalidParameters, nodeSpan(ident), "duplicate parameter name: %s", ident.Value)
+			return false
+		}
+		seen[ident.Value] = true
+
+		if rest {
+			lit.Rest = ident
+			if p.peekTokenIs(token.COMMA) {
+				p.errorAt(CodeInvalidParameters, diagnostics.SpanOf(p.peekToken), "rest parameter ...%s must be the last parameter", ident.Value)
+				return false
+			}
+			break
+		}
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults {
+			p.errorAt(CodeInvalidParameters, nodeSpan(ident), "parameter %s without default follows parameter with default", ident.Value)
+			return false
+		}
+
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Defaults = append(lit.Defaults, def)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		d := p.errorAt(CodeUnexpectedToken, diagnostics.SpanOf(p.peekToken), "expected `,` or `)` after parameter, got %s", describeToken(p.peekToken))
+		// Un nome che segue un parametro è quasi certamente il parametro successivo.
+		if d != nil && (p.peekTokenIs(token.IDENT) || p.peekTokenIs(token.ELLIPSIS)) {
+			d.Fix = p.insertAfterCurrent(",")
+		}
+		return false
+	}
 	p.nextToken()
+	return true
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "argument")
+	if p.curTokenIs(token.RPAREN) {
+		exp.RParen = p.curToken
+	}
+	return exp
+}
+
+// parseExpressionList analizza una lista di espressioni separate da virgole e terminata
+// dal token end. È condivisa dagli argomenti delle chiamate e dagli array letterali;
+// item nomina un elemento della lista nei messaggi di errore.
+func (p *Parser) parseExpressionList(end token.TokenType, item string) []ast.Expression {
+	open := p.curToken
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.peekTokenIs(end) {
+		d := p.errorAt(CodeUnexpectedToken, diagnostics.SpanOf(p.peekToken), "expected `,` or %s after %s, got %s",
+			describeType(end), item, describeToken(p.peekToken))
+		p.unclosedList(d, open, end)
 		return nil
 	}
+	p.nextToken()
 
-	return identifiers
+	return list
 }
 
-func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+// parseArrayLiteral analizza un array letterale, es. "[1, 2, 3]".
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET, "array element")
+	if p.curTokenIs(token.RBRACKET) {
+		array.RBracket = p.curToken
+	}
+	return array
+}
+
+// parseIndexExpression analizza l'accesso tramite indice, es. "myArray[1 + 1]".
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectClosing(token.RBRACKET, "index", exp.Token) {
+		return nil
+	}
+
+	exp.RBracket = p.curToken
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+// parseHashLiteral analizza una mappa letterale, es. {"a": 1, "b": 2}.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
 
-	if p.peekTokenIs(token.RPAREN) {
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeekAfter(token.COLON, "hash key") {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			d := p.errorAt(CodeUnexpectedToken, diagnostics.SpanOf(p.peekToken), "expected `,` or `}` after hash value, got %s", describeToken(p.peekToken))
+			p.unclosedList(d, hash.Token, token.RBRACE)
+			return nil
+		}
 		p.nextToken()
-		return args
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
 
-	for p.peekTokenIs(token.COMMA) {
+	hash.RBrace = p.curToken
+	return hash
+}
+
+// parseWhileStatement analizza un ciclo "while (condizione) { ... }".
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeekAfter(token.LPAREN, "`while`") {
+		return nil
+	}
+	open := p.curToken
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectClosing(token.RPAREN, "loop condition", open) {
+		return nil
+	}
+
+	if !p.expectPeekAfter(token.LBRACE, "`while (...)`") {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	// Come dopo le altre istruzioni, il ';' finale è facoltativo.
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement analizza un ciclo "for (init; condizione; aggiornamento) { ... }".
+// Init e aggiornamento sono istruzioni qualsiasi (tipicamente un 'let' o un'espressione).
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeekAfter(token.LPAREN, "`for`") {
+		return nil
+	}
+	open := p.curToken
+
+	// Inizializzazione: al termine curToken deve essere il primo ';'
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Init = p.parseStatement()
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeekAfter(token.SEMICOLON, "loop initializer") {
+			return nil
+		}
+	}
+
+	// Condizione: al termine curToken deve essere il secondo ';'
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeekAfter(token.SEMICOLON, "loop condition") {
+			return nil
+		}
+	}
+
+	// Aggiornamento: al termine curToken deve essere la ')'
+	p.nextToken()
+	if !p.curTokenIs(token.RPAREN) {
+		stmt.Update = p.parseStatement()
+		if !p.expectClosing(token.RPAREN, "loop update", open) {
+			return nil
+		}
+	}
+
+	if !p.expectPeekAfter(token.LBRACE, "`for (...)`") {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	// Come dopo le altre istruzioni, il ';' finale è facoltativo.
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopBody analizza il blocco di un ciclo, in cui 'break' e 'continue' sono ammessi.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+// parseBreakStatement analizza l'istruzione 'break'.
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorAt(CodeOutsideLoop, diagnostics.SpanOf(p.curToken), "'break' outside of a loop")
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseContinueStatement analizza l'istruzione 'continue'.
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorAt(CodeOutsideLoop, diagnostics.SpanOf(p.curToken), "'continue' outside of a loop")
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseThrowStatement analizza l'istruzione "throw espressione".
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseTryExpression analizza "try { ... } catch (e) { ... } finally { ... }".
+// Il nome tra parentesi dopo 'catch' è facoltativo, così come uno dei due
+// blocchi 'catch' e 'finally', ma non entrambi.
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeekAfter(token.LBRACE, "`try`") {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
 		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			open := p.curToken
+			if !p.expectPeekAfter(token.IDENT, "`catch (`") {
+				return nil
+			}
+			expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectClosing(token.RPAREN, "catch parameter", open) {
+				return nil
+			}
+		}
+
+		if !p.expectPeekAfter(token.LBRACE, "`catch`") {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		if !p.expectPeekAfter(token.LBRACE, "`finally`") {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorAt(CodeUnexpectedToken, diagnostics.SpanOf(p.peekToken),
+			"expected `catch` or `finally` after try block, got %s", describeToken(p.peekToken))
 		return nil
 	}
 
-	return args
+	return expression
 }
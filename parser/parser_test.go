@@ -141,6 +141,15 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
 	}
 
 	for _, tt := range infixTests {
@@ -179,6 +188,36 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"2 / (5 + 5)", "(2 / (5 + 5))"},
 		{"-(5 + 5)", "(-(5 + 5))"},
 		{"!(true == true)", "(!(true == true))"},
+		// Accesso tramite indice
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		// L'operatore postfisso '?' lega più di qualunque altro
+		{"f(x)? + 1", "((f(x)?) + 1)"},
+		{"-a?", "(-(a?))"},
+		{"a[0]?", "((a[0])?)"},
+		{"a?[0]", "((a?)[0])"},
+		{"a??", "((a?)?)"},
+		{"f(x)[0]", "(f(x)[0])"},
+		// Operatori di confronto, logici, bit a bit e potenza
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b == c", "(a & (b == c))"},
+		{"1 << 2 + 3", "(1 << (2 + 3))"},
+		{"a < b << c", "(a < (b << c))"},
+		{"a % b * c", "((a % b) * c)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"~a & b", "((~a) & b)"},
+		// Assegnamenti
+		{"x = 1 + 2", "(x = (1 + 2))"},
+		{"a = b = c", "(a = (b = c))"},
+		{"x += y || z", "(x += (y || z))"},
+		{"arr[i + 1] *= 2", "((arr[(i + 1)]) *= 2)"},
 	}
 
 	for _, tt := range tests {
@@ -444,6 +483,65 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y = 10) {}", "fn(x, y = 10) "},
+		{"fn(x = 1, y = x * 2) {}", "fn(x = 1, y = (x * 2)) "},
+		{"fn(...rest) {}", "fn(...rest) "},
+		{"fn(first, second = 2, ...rest) {}", "fn(first, second = 2, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+		if function.String() != tt.expected {
+			t.Errorf("wrong function. expected=%q, got=%q", tt.expected, function.String())
+		}
+		if len(function.Defaults) != len(function.Parameters) {
+			t.Errorf("defaults not aligned with parameters. got=%d defaults for %d parameters",
+				len(function.Defaults), len(function.Parameters))
+		}
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x = 1, y) {}", "1:11: parameter y without default follows parameter with default"},
+		{"fn(...rest, x) {}", "1:11: rest parameter ...rest must be the last parameter"},
+		{"fn(x, x) {}", "1:7: duplicate parameter name: x"},
+		{"fn(1) {}", "1:4: expected parameter name, got number `1`"},
+		{"fn(...) {}", "1:7: expected identifier after `...`, got `)`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -545,3 +643,804 @@ func TestReturnStatements(t *testing.T) {
 		}
 	}
 }
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+
+	if literal.String() != `"hello world"` {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key[%d] wrong. want=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+
+	if hash.String() != `{"one": 1, "two": 2, "three": 3}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	l := lexer.New("{}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, true: 10 - 8, 3: 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testBooleanLiteral(t, hash.Pairs[1].Key, true)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testIntegerLiteral(t, hash.Pairs[2].Key, 3)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+}
+
+func TestHashLiteralInsideBlock(t *testing.T) {
+	input := `if (true) { {"a": 1} }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp := stmt.Expression.(*ast.IfExpression)
+	inner := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+
+	if _, ok := inner.Expression.(*ast.HashLiteral); !ok {
+		t.Fatalf("inner expression is not ast.HashLiteral. got=%T", inner.Expression)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{".5;", 0.5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		left     interface{}
+		operator string
+		right    interface{}
+	}{
+		{"a && b", "a", "&&", "b"},
+		{"true || false", true, "||", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+
+		testLiteralExpression(t, exp.Left, tt.left)
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+		testLiteralExpression(t, exp.Right, tt.right)
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; let i = i + 1) { continue; }", "for (let i = 0; (i < 10); let i = (i + 1)) continue;"},
+		{"for (;;) { break; }", "for (; ; ) break;"},
+		{"for (i; i; i) { }", "for (i; i; i) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		if _, ok := program.Statements[0].(*ast.ForStatement); !ok {
+			t.Fatalf("stmt is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestLoopTrailingSemicolon(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int
+	}{
+		{"while (false) { };", 1},
+		{"for (;;) { break; };", 1},
+		{"while (false) { }; 5", 2},
+		{"for (;;) { break }; let x = 1;", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: expected %d statements. got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: 'break' outside of a loop"},
+		{"continue;", "1:1: 'continue' outside of a loop"},
+		{"while (true) { fn() { break; } }", "1:23: 'break' outside of a loop"},
+		{"if (true) { continue }", "1:13: 'continue' outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 parser error for %q. got=%v", tt.input, errors)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+	}{
+		{"x = 5;", "="},
+		{"x += 5;", "+="},
+		{"x -= 5;", "-="},
+		{"x *= 5;", "*="},
+		{"x /= 5;", "/="},
+		{"x %= 5;", "%="},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, exp.Target, "x")
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+		testIntegerLiteral(t, exp.Value, 5)
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 = 3", "1:1: invalid assignment target: 5"},
+		{"f() = 3", "1:1: invalid assignment target: f()"},
+		{"a + b = 3", "1:1: invalid assignment target: (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `// restituisce il doppio
+let double = fn(x) { x * 2 /* moltiplica */ };
+double(/* argomento */ 3); // chiamata`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	if len(letStmt.Token.Trivia) != 1 || letStmt.Token.Trivia[0].Literal != "// restituisce il doppio" {
+		t.Errorf("let token trivia wrong. got=%v", letStmt.Token.Trivia)
+	}
+
+	expected := []string{"// restituisce il doppio", "/* moltiplica */", "/* argomento */", "// chiamata"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments wrong length. want=%d, got=%d", len(expected), len(program.Comments))
+	}
+	for i, comment := range program.Comments {
+		if comment.Literal != expected[i] {
+			t.Errorf("program.Comments[%d] wrong. want=%q, got=%q", i, expected[i], comment.Literal)
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let x = 1 + 2;\nadd(x, [1, 2]);"
+
+	l := lexer.New(input, lexer.WithFilename("pos.mk"))
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "pos.mk:1:1", "pos.mk:2:15"},
+		{program.Statements[0], "pos.mk:1:1", "pos.mk:1:14"},
+		{program.Statements[0].(*ast.LetStatement).Value, "pos.mk:1:9", "pos.mk:1:14"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression, "pos.mk:2:1", "pos.mk:2:15"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "pos.mk:2:8", "pos.mk:2:14"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.expectedStart {
+			t.Errorf("tests[%d] (%s) wrong Pos. want=%s, got=%s", i, tt.node.String(), tt.expectedStart, got)
+		}
+		if got := tt.node.End().String(); got != tt.expectedEnd {
+			t.Errorf("tests[%d] (%s) wrong End. want=%s, got=%s", i, tt.node.String(), tt.expectedEnd, got)
+		}
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	l := lexer.New("let x = 5;\nlet = 10;", lexer.WithFilename("main.mk"))
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	expected := "main.mk:2:5: expected identifier after `let`, got `=`"
+	if errors[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestFailedStatementsAreNotAppended(t *testing.T) {
+	l := lexer.New("let = 5; let x 5; 7;")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	for i, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let == nil {
+			t.Errorf("program.Statements[%d] is a nil *ast.LetStatement", i)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"let x = 5 +; let y = 10;",
+			[]string{"1:12: expected expression, got `;`"},
+			[]string{"let y = 10;"},
+		},
+		{
+			"add(1 2); let y = 3;",
+			[]string{"1:7: expected `,` or `)` after argument, got number `2`"},
+			[]string{"let y = 3;"},
+		},
+		{
+			"add(1, ); y",
+			[]string{"1:8: expected expression, got `)`"},
+			[]string{"y"},
+		},
+		{
+			"let = 1; let z 2; z",
+			[]string{
+				"1:5: expected identifier after `let`, got `=`",
+				"1:16: expected `=` after `let z`, got number `2`",
+			},
+			[]string{"z"},
+		},
+		{
+			`let h = {"a" 1, "b": 2}; let ok = true;`,
+			[]string{"1:14: expected `:` after hash key, got number `1`"},
+			[]string{"let ok = true;"},
+		},
+		{
+			"let f = fn(x) { x + }; let y = 1;",
+			[]string{"1:21: expected expression, got `}`"},
+			[]string{"let f = fn(x) ;", "let y = 1;"},
+		},
+		{
+			"if (x { 1 } let y = 2",
+			[]string{"1:7: expected `)` after if condition, got `{`"},
+			[]string{},
+		},
+		{
+			"while (true) { let = 1; break; }",
+			[]string{"1:20: expected identifier after `let`, got `=`"},
+			[]string{"whiletrue break;"},
+		},
+		{
+			"let a = 1 @ 2; a",
+			[]string{"1:11: expected expression, got invalid token `@`"},
+			[]string{"let a = 1;", "a"},
+		},
+		{
+			"(1 + ); let y = 1; y",
+			[]string{"1:6: expected expression, got `)`"},
+			[]string{"let y = 1;", "y"},
+		},
+		{
+			"let f = fn() { (1 + ); let y = 1; y }; 5",
+			[]string{"1:21: expected expression, got `)`"},
+			[]string{"let f = fn() let y = 1;y;", "5"},
+		},
+		{
+			"if (true) { [1 2] } let z = 1;",
+			[]string{"1:16: expected `,` or `]` after array element, got number `2`"},
+			[]string{"iftrue ", "let z = 1;"},
+		},
+		{
+			"}}}",
+			[]string{"1:1: expected expression, got `}`"},
+			[]string{},
+		},
+		{
+			"let a = 1; } } }\nlet b = 2; }",
+			[]string{
+				"1:12: expected expression, got `}`",
+				"2:12: expected expression, got `}`",
+			},
+			[]string{"let a = 1;", "let b = 2;"},
+		},
+		{
+			"}; }",
+			[]string{
+				"1:1: expected expression, got `}`",
+				"1:4: expected expression, got `}`",
+			},
+			[]string{},
+		},
+		{
+			"let a = 1;\n/* aperto\nlet b = 2;",
+			[]string{"2:1: unterminated block comment"},
+			[]string{"let a = 1;"},
+		},
+		{
+			`let s = "abc`,
+			[]string{"1:9: unterminated string literal"},
+			[]string{},
+		},
+		{
+			`let s = "a\qb"; let t = "\u{zz}"; puts(1 "x`,
+			[]string{
+				"1:9: invalid escape sequence `\\q`",
+				"1:25: invalid unicode escape `\\u{zz}`",
+				"1:42: expected `,` or `)` after argument, got unterminated string literal",
+			},
+			[]string{},
+		},
+		{
+			"let v = 1.5.2; let w = 1e; v",
+			[]string{
+				"1:9: expected expression, got invalid token `1.5.2`",
+				"1:24: expected expression, got invalid token `1e`",
+			},
+			[]string{"v"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, expected := range tt.expectedErrors {
+			if errors[i] != expected {
+				t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, expected, errors[i])
+			}
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("wrong number of statements for %q. expected=%q, got=%q", tt.input, tt.expectedStatements, program.String())
+			continue
+		}
+		for i, expected := range tt.expectedStatements {
+			if got := program.Statements[i].String(); got != expected {
+				t.Errorf("wrong statement %d for %q. expected=%q, got=%q", i, tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedCode   string
+		expectedSpan   string // "inizio-fine" dello span principale
+		expectedLabels []string
+		expectedFix    string // sostituzione suggerita, vuota se non c'è
+	}{
+		{"let x = 5 +;", CodeExpectedExpr, "1:12-1:13", nil, ""},
+		{"add(1, 2;", CodeUnexpectedToken, "1:9-1:10", []string{"1:4: to match this `(`"}, ")"},
+		{"[1, 2", CodeUnexpectedToken, "1:6-1:6", []string{"1:1: to match this `[`"}, "]"},
+		{`{"a": 1 "b"`, CodeUnexpectedToken, "1:9-1:12", []string{"1:1: to match this `{`"}, ","},
+		{`{"a": 1;`, CodeUnexpectedToken, "1:8-1:9", []string{"1:1: to match this `{`"}, "}"},
+		{"add(1 2)", CodeUnexpectedToken, "1:7-1:8", []string{"1:4: to match this `(`"}, ","},
+		{"add(x y)", CodeUnexpectedToken, "1:7-1:8", []string{"1:4: to match this `(`"}, ","},
+		{"[1 2]", CodeUnexpectedToken, "1:4-1:5", []string{"1:1: to match this `[`"}, ","},
+		{"[1, 2 @]", CodeUnexpectedToken, "1:7-1:8", []string{"1:1: to match this `[`"}, "]"},
+		{"fn(a b) { a }", CodeUnexpectedToken, "1:6-1:7", nil, ","},
+		{"fn(a ...b) { a }", CodeUnexpectedToken, "1:6-1:9", nil, ","},
+		{"fn(a { a }", CodeUnexpectedToken, "1:6-1:7", nil, ""},
+		{"(1 + 2", CodeUnexpectedToken, "1:7-1:7", []string{"1:1: to match this `(`"}, ")"},
+		{"while (x { }", CodeUnexpectedToken, "1:10-1:11", []string{"1:7: to match this `(`"}, ")"},
+		{"a + b = 1", CodeInvalidAssignment, "1:1-1:6", nil, "=="},
+		{"1 += 2", CodeInvalidAssignment, "1:1-1:2", nil, ""},
+		{"break;", CodeOutsideLoop, "1:1-1:6", nil, ""},
+		{"fn(x, x) {}", CodeInvalidParameters, "1:7-1:8", nil, ""},
+		{`let s = "abc`, CodeInvalidToken, "1:9-1:13", nil, ""},
+		{"1 + /* aperto", CodeInvalidToken, "1:5-1:14", nil, ""},
+		{`let s = "a\qb";`, CodeInvalidToken, "1:9-1:15", nil, ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%q: expected diagnostics", tt.input)
+			continue
+		}
+		d := diags[0]
+
+		if d.Code != tt.expectedCode {
+			t.Errorf("%q: wrong code. expected=%s, got=%s (%s)", tt.input, tt.expectedCode, d.Code, d.Message)
+		}
+		if span := d.Span.Start.String() + "-" + d.Span.End.String(); span != tt.expectedSpan {
+			t.Errorf("%q: wrong span. expected=%s, got=%s", tt.input, tt.expectedSpan, span)
+		}
+
+		var labels []string
+		for _, label := range d.Labels {
+			labels = append(labels, label.Span.Start.String()+": "+label.Message)
+		}
+		if fmt.Sprint(labels) != fmt.Sprint(tt.expectedLabels) {
+			t.Errorf("%q: wrong labels. expected=%q, got=%q", tt.input, tt.expectedLabels, labels)
+		}
+
+		fix := ""
+		if d.Fix != nil {
+			fix = d.Fix.Replacement
+		}
+		if fix != tt.expectedFix {
+			t.Errorf("%q: wrong fix. expected=%q, got=%q", tt.input, tt.expectedFix, fix)
+		}
+
+		if got := p.Errors()[0]; got != d.String() {
+			t.Errorf("%q: Errors() and Diagnostics() disagree: %q vs %q", tt.input, got, d.String())
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x } catch (e) { e }", "try x catch (e) e"},
+		{"try { x } catch { 1 }", "try x catch 1"},
+		{"try { x } finally { y }", "try x finally y"},
+		{"try { x } catch (e) { e } finally { y }", "try x catch (e) e finally y"},
+		{"throw 1 + 2;", "throw (1 + 2);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x }", "1:10: expected `catch` or `finally` after try block, got end of input"},
+		{"try x", "1:5: expected `{` after `try`, got identifier `x`"},
+		{"try { x } catch (1) { }", "1:18: expected identifier after `catch (`, got number `1`"},
+		{"try { x } catch (e { }", "1:20: expected `)` after catch parameter, got `{`"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
@@ -4,12 +4,20 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"monkey-interpreter/ast"
+	"monkey-interpreter/compiler"
+	"monkey-interpreter/diagnostics"
 	"monkey-interpreter/evaluator"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/object" // Assicurati che questo import sia presente
 	"monkey-interpreter/parser"
+	"monkey-interpreter/resolver"
+	"monkey-interpreter/vm"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -27,12 +35,37 @@ const MONKEY_FACE = `
                    '-----'
 `
 
-// Start avvia il ciclo Read-Eval-Print-Loop.
+// Engine indica quale motore esegue il codice.
+type Engine string
+
+const (
+	EngineEval     Engine = "eval"     // L'evaluator che percorre l'AST
+	EngineResolved Engine = "resolved" // L'evaluator, con le variabili risolte prima dell'esecuzione
+	EngineVM       Engine = "vm"       // Il compilatore a bytecode e la macchina virtuale
+)
+
+// ParseEngine riconosce il nome di un motore.
+func ParseEngine(name string) (Engine, error) {
+	switch Engine(name) {
+	case EngineEval, EngineResolved, EngineVM:
+		return Engine(name), nil
+	}
+	return "", fmt.Errorf("unknown engine %q (want %q, %q or %q)", name, EngineEval, EngineResolved, EngineVM)
+}
+
+// Start avvia il ciclo Read-Eval-Print-Loop con l'evaluator.
 func Start(in io.Reader, out io.Writer) {
+	StartWithEngine(in, out, EngineEval)
+}
+
+/*
+StartWithEngine avvia il REPL con il motore indicato. Il comando ":engine vm"
+(o ":engine eval", ":engine resolved") cambia motore durante la sessione; ogni
+motore conserva le proprie variabili, che non sono condivise con gli altri.
+*/
+func StartWithEngine(in io.Reader, out io.Writer, engine Engine) {
 	scanner := bufio.NewScanner(in)
-	// Crea un singolo Environment che verrà riutilizzato per tutta la sessione del REPL.
-	// Questo permette di mantenere lo stato (le variabili) tra un input e l'altro.
-	env := object.NewEnvironment()
+	s := newSession(engine)
 
 	for {
 		fmt.Fprint(out, PROMPT)
@@ -42,32 +75,138 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), ":engine") {
+			s.switchEngine(out, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), ":engine")))
+			continue
+		}
+
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Diagnostics())
+			continue
+		}
+
+		// Una riga vuota non produce nulla da mostrare.
+		if len(program.Statements) == 0 {
 			continue
 		}
 
+		// Con il resolver, i nomi non dichiarati sono errori prima dell'esecuzione.
+		if s.engine == EngineResolved {
+			if diags := s.resolver.Resolve(program); len(diags) != 0 {
+				printResolverErrors(out, line, diags)
+				continue
+			}
+		}
+
+		evaluated := s.run(program)
+
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
+
+// session contiene lo stato del REPL per tutti i motori.
+type session struct {
+	engine Engine
+
+	// Crea un singolo Environment che verrà riutilizzato per tutta la sessione del REPL.
+	// Questo permette di mantenere lo stato (le variabili) tra un input e l'altro.
+	env *object.Environment
+
+	// Il motore "resolved" ha un ambiente suo: i suoi globali sono negli slot
+	// assegnati dal resolver, che li ricorda tra una riga e l'altra.
+	resolver    *resolver.Resolver
+	resolvedEnv *object.Environment
+
+	// Lo stesso per la VM: i globali e le costanti sopravvivono tra una riga e l'altra.
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+func newSession(engine Engine) *session {
+	return &session{
+		engine:      engine,
+		env:         object.NewEnvironment(),
+		resolver:    resolver.New(),
+		resolvedEnv: object.NewEnvironment(),
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+	}
+}
+
+// switchEngine gestisce il comando ":engine"; senza argomenti mostra il motore attuale.
+func (s *session) switchEngine(out io.Writer, name string) {
+	if name != "" {
+		engine, err := ParseEngine(name)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		s.engine = engine
+	}
+	fmt.Fprintf(out, "engine: %s\n", s.engine)
+}
+
+// run esegue program con il motore attuale. Come evaluator.Run, nessun motore
+// lascia arrivare un panic interno al REPL.
+func (s *session) run(program *ast.Program) object.Object {
+	switch s.engine {
+	case EngineEval:
 		// Passa sia l'AST (program) che l'ambiente (env) all'evaluator.
 		// L'evaluator userà 'env' per leggere e scrivere le variabili.
-		evaluated := evaluator.Eval(program, env)
+		return evaluator.Run(program, s.env)
+	case EngineResolved:
+		return evaluator.Run(program, s.resolvedEnv)
+	}
 
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
-		}
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	if err := comp.Compile(program); err != nil {
+		// Gli errori di compilazione hanno gli stessi messaggi dell'evaluator
+		// (es. "invalid program: ..."): li mostriamo allo stesso modo.
+		return &object.Error{Message: err.Error()}
 	}
+	s.constants = comp.Constants()
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), s.globals)
+	return machine.Run(context.Background())
 }
 
-// printParserErrors stampa gli errori del parser in un formato carino.
-func printParserErrors(out io.Writer, errors []string) {
+// printParserErrors stampa gli errori del parser in un formato carino, mostrando
+// la riga di sorgente con il punto dell'errore sottolineato.
+func printParserErrors(out io.Writer, source string, diags []diagnostics.Diagnostic) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Ops! Abbiamo incontrato un problema con la scimmia!\n")
 	io.WriteString(out, "Errori del parser:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	renderer := diagnostics.TextRenderer{Source: source, Color: useColor(out)}
+	renderer.Render(out, diags)
+}
+
+// printResolverErrors stampa i nomi non risolti con lo stesso formato degli
+// errori del parser.
+func printResolverErrors(out io.Writer, source string, diags []diagnostics.Diagnostic) {
+	io.WriteString(out, MONKEY_FACE)
+	io.WriteString(out, "Ops! Abbiamo incontrato un problema con la scimmia!\n")
+	io.WriteString(out, "Errori del resolver:\n")
+	renderer := diagnostics.TextRenderer{Source: source, Color: useColor(out)}
+	renderer.Render(out, diags)
+}
+
+// useColor dice se out è un terminale che può mostrare i colori ANSI.
+// Come d'uso, la variabile d'ambiente NO_COLOR li disattiva.
+func useColor(out io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
 	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,278 @@
+// File: resolver/resolver.go
+
+/*
+Package resolver risolve staticamente le variabili di un programma, prima che
+venga eseguito. Per ogni identificatore calcola se indica una variabile locale,
+catturata da una funzione esterna o globale, e in quale slot di quale ambiente
+si trova (ast.Binding); per ogni funzione e blocco catch calcola gli slot del
+suo ambiente (ast.Scope). L'evaluator usa queste informazioni per leggere le
+variabili da un array invece di cercarle per nome, ambiente dopo ambiente.
+
+Gli scope sono quelli dell'evaluator: il programma, ogni funzione e ogni blocco
+catch. Una variabile dichiarata con `let` ha uno slot nello scope che la contiene
+dall'inizio alla fine, ma come nell'evaluator il nome si riferisce alla variabile
+omonima di uno scope esterno finché il `let` non viene eseguito: il Binding di
+un identificatore elenca quindi, tramite Shadowed, tutte le dichiarazioni a cui
+può riferirsi, fino a un parametro o a un globale.
+
+I nomi che non sono dichiarati in nessuno scope e non sono funzioni built-in
+vengono segnalati come errori, senza eseguire il programma.
+*/
+package resolver
+
+import (
+	"fmt"
+	"monkey-interpreter/ast"
+	"monkey-interpreter/diagnostics"
+	"monkey-interpreter/evaluator"
+)
+
+// Codici delle diagnostiche del resolver.
+const (
+	CodeUnresolvedName     = "S001" // Identificatore mai dichiarato
+	CodeUndeclaredAssignee = "S002" // Assegnamento a una variabile mai dichiarata
+)
+
+// scope è un ambiente del programma risolto: names[i] è la variabile nello slot i.
+// lets contiene i nomi dichiarati con `let`, che non hanno un valore fin dall'ingresso.
+type scope struct {
+	outer *scope
+	slots map[string]int
+	names []string
+	lets  map[string]bool
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, slots: make(map[string]int), lets: make(map[string]bool)}
+}
+
+// declare dichiara name in questo scope e ne restituisce lo slot. Ridichiarare
+// un nome restituisce lo stesso slot, come `let` sovrascrive una variabile esistente.
+func (s *scope) declare(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	s.slots[name] = len(s.names)
+	s.names = append(s.names, name)
+	return s.slots[name]
+}
+
+// hoist dichiara i nomi dei `let` di uno scope. Un parametro con lo stesso nome
+// ha sempre un valore, quindi resta tale.
+func (s *scope) hoist(names []string) {
+	for _, name := range names {
+		if _, ok := s.slots[name]; !ok {
+			s.lets[name] = true
+			s.declare(name)
+		}
+	}
+}
+
+/*
+Resolver risolve uno o più programmi. Le variabili globali sopravvivono da un
+programma all'altro, così che un REPL possa risolvere ogni riga separatamente
+ed eseguirle tutte nello stesso ambiente.
+*/
+type Resolver struct {
+	globals     *scope
+	diagnostics []diagnostics.Diagnostic
+}
+
+// New crea un resolver senza variabili globali.
+func New() *Resolver {
+	return &Resolver{globals: newScope(nil)}
+}
+
+/*
+Resolve annota program con le posizioni delle variabili e restituisce gli
+errori trovati. Se ce ne sono, il programma non va eseguito: alcuni
+identificatori sono rimasti senza Binding.
+*/
+func (r *Resolver) Resolve(program *ast.Program) []diagnostics.Diagnostic {
+	r.diagnostics = nil
+	r.globals.hoist(ast.HoistedLets(program))
+	for _, s := range program.Statements {
+		r.resolve(s, r.globals)
+	}
+	return r.diagnostics
+}
+
+// resolve annota node, che si trova nello scope s.
+func (r *Resolver) resolve(node ast.Node, s *scope) {
+	if ast.IsNil(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.resolveIdentifier(node, s, nil)
+
+	case *ast.LetStatement:
+		r.resolve(node.Value, s)
+		if node.Name != nil {
+			r.bindDeclaration(node.Name, s)
+		}
+
+	case *ast.AssignExpression:
+		if target, ok := node.Target.(*ast.Identifier); ok {
+			r.resolveIdentifier(target, s, node)
+		} else {
+			r.resolve(node.Target, s)
+		}
+		r.resolve(node.Value, s)
+
+	case *ast.FunctionLiteral:
+		fs := newScope(s)
+		for _, p := range node.Parameters {
+			fs.declare(p.Value)
+		}
+		if node.Rest != nil {
+			fs.declare(node.Rest.Value)
+		}
+		fs.hoist(ast.HoistedLets(node.Body))
+
+		// I valori predefiniti sono valutati nell'ambiente della funzione.
+		for i, p := range node.Parameters {
+			r.bindDeclaration(p, fs)
+			if i < len(node.Defaults) && node.Defaults[i] != nil {
+				r.resolve(node.Defaults[i], fs)
+			}
+		}
+		if node.Rest != nil {
+			r.bindDeclaration(node.Rest, fs)
+		}
+		r.resolve(node.Body, fs)
+		node.Scope = &ast.Scope{Names: fs.names}
+
+	case *ast.TryExpression:
+		r.resolve(node.Block, s)
+		if node.Catch != nil {
+			cs := newScope(s)
+			if node.Param != nil {
+				cs.declare(node.Param.Value)
+				r.bindDeclaration(node.Param, cs)
+			}
+			cs.hoist(ast.HoistedLets(node.Catch))
+			r.resolve(node.Catch, cs)
+			node.CatchScope = &ast.Scope{Names: cs.names}
+		}
+		r.resolve(node.Finally, s)
+
+	default:
+		// Gli altri nodi non introducono scope: basta risolverne i figli.
+		ast.Inspect(node, func(n ast.Node) bool {
+			if n == node {
+				return true
+			}
+			r.resolve(n, s)
+			return false
+		})
+	}
+}
+
+// bindDeclaration annota un identificatore che dichiara una variabile di s.
+func (r *Resolver) bindDeclaration(name *ast.Identifier, s *scope) {
+	binding := &ast.Binding{Scope: ast.LocalBinding, Slot: s.declare(name.Value)}
+	if s == r.globals {
+		binding.Scope = ast.GlobalBinding
+	}
+	name.Binding = binding
+}
+
+// resolveIdentifier annota un identificatore che usa una variabile, o che è il
+// bersaglio dell'assegnamento assign se questo non è nil.
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier, s *scope, assign *ast.AssignExpression) {
+	var last *ast.Binding
+	depth := 0
+	for current := s; current != nil; current, depth = current.outer, depth+1 {
+		slot, ok := current.slots[ident.Value]
+		if !ok {
+			continue
+		}
+		binding := &ast.Binding{Scope: ast.LocalBinding, Depth: depth, Slot: slot}
+		switch {
+		case current == r.globals:
+			binding.Scope = ast.GlobalBinding
+		case depth > 0:
+			binding.Scope = ast.CapturedBinding
+		}
+		if last == nil {
+			ident.Binding = binding
+		} else {
+			last.Shadowed = binding
+		}
+		last = binding
+		// Prima del suo `let` il nome si riferisce alla dichiarazione esterna.
+		if !current.lets[ident.Value] {
+			break
+		}
+	}
+	if ident.Binding != nil {
+		return
+	}
+
+	// Le funzioni built-in si possono chiamare, ma non riassegnare senza un `let`.
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok && assign == nil {
+		ident.Binding = &ast.Binding{Scope: ast.BuiltinBinding}
+		return
+	}
+
+	d := diagnostics.Diagnostic{
+		Severity: diagnostics.Error,
+		Code:     CodeUnresolvedName,
+		Message:  fmt.Sprintf("identifier not found: %s", ident.Value),
+		Span:     diagnostics.SpanOf(ident.Token),
+	}
+	if assign != nil {
+		// Come a runtime, l'errore di un assegnamento è sull'operatore.
+		d.Span = diagnostics.SpanOf(assign.Token)
+		d.Code = CodeUndeclaredAssignee
+		d.Message = fmt.Sprintf("assignment to undeclared identifier: %s", ident.Value)
+		d.Notes = []string{fmt.Sprintf("declare it first with `let %s = ...`", ident.Value)}
+	}
+	if suggestion, ok := similarName(ident.Value, s); ok {
+		d.Fix = &diagnostics.Fix{
+			Message:     fmt.Sprintf("did you mean `%s`?", suggestion),
+			Span:        diagnostics.SpanOf(ident.Token),
+			Replacement: suggestion,
+		}
+	}
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+// similarName cerca tra le variabili visibili da s un nome che differisca da
+// name per al massimo un terzo dei caratteri (almeno uno, ma mai tutti), per
+// suggerire una correzione a un errore di battitura.
+func similarName(name string, s *scope) (string, bool) {
+	best, bestDistance := "", max(1, len(name)/3)+1
+	for current := s; current != nil; current = current.outer {
+		for _, candidate := range current.names {
+			if d := editDistance(name, candidate); d < bestDistance && d < len(name) {
+				best, bestDistance = candidate, d
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance calcola la distanza di Levenshtein tra a e b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
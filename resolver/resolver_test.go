@@ -0,0 +1,246 @@
+// resolver/resolver_test.go
+package resolver
+
+import (
+	"monkey-interpreter/ast"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+// describe riassume un risultato per il confronto tra le due modalità: per un
+// errore include posizione e traccia, oltre al messaggio.
+func describe(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return err.Inspect() + "\n" + err.Traceback()
+	}
+	return obj.Inspect()
+}
+
+func TestBindings(t *testing.T) {
+	program := parse(`let g = 1;
+let f = fn(a) {
+  let b = a + g;
+  fn() { b + len([]) }
+};
+try { 1 } catch (e) { e }`)
+	if diags := New().Resolve(program); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	// Raccoglie le occorrenze di ogni nome in ordine di sorgente.
+	found := map[string][]*ast.Binding{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			found[ident.Value] = append(found[ident.Value], ident.Binding)
+		}
+		return true
+	})
+
+	tests := []struct {
+		name     string
+		index    int
+		expected ast.Binding
+	}{
+		{"g", 0, ast.Binding{Scope: ast.GlobalBinding, Depth: 0, Slot: 0}},
+		{"f", 0, ast.Binding{Scope: ast.GlobalBinding, Depth: 0, Slot: 1}},
+		{"a", 0, ast.Binding{Scope: ast.LocalBinding, Depth: 0, Slot: 0}},
+		{"b", 0, ast.Binding{Scope: ast.LocalBinding, Depth: 0, Slot: 1}},
+		{"a", 1, ast.Binding{Scope: ast.LocalBinding, Depth: 0, Slot: 0}},
+		{"g", 1, ast.Binding{Scope: ast.GlobalBinding, Depth: 1, Slot: 0}},
+		{"b", 1, ast.Binding{Scope: ast.CapturedBinding, Depth: 1, Slot: 1}},
+		{"len", 0, ast.Binding{Scope: ast.BuiltinBinding}},
+		{"e", 0, ast.Binding{Scope: ast.LocalBinding, Depth: 0, Slot: 0}},
+		{"e", 1, ast.Binding{Scope: ast.LocalBinding, Depth: 0, Slot: 0}},
+	}
+
+	for _, tt := range tests {
+		bindings := found[tt.name]
+		if tt.index >= len(bindings) || bindings[tt.index] == nil {
+			t.Errorf("%s #%d: not resolved", tt.name, tt.index)
+			continue
+		}
+		if *bindings[tt.index] != tt.expected {
+			t.Errorf("%s #%d: wrong binding. got=%+v, want=%+v", tt.name, tt.index, *bindings[tt.index], tt.expected)
+		}
+	}
+
+	fn := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if fn.Scope == nil || len(fn.Scope.Names) != 2 {
+		t.Errorf("wrong function scope: %+v", fn.Scope)
+	}
+}
+
+func TestShadowedBindings(t *testing.T) {
+	program := parse("let a = 1; let f = fn(p) { let b = a; let a = 2; fn() { a + p } }")
+	if diags := New().Resolve(program); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	var uses []*ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && (ident.Value == "a" || ident.Value == "p") {
+			uses = append(uses, ident)
+		}
+		return true
+	})
+
+	// Le dichiarazioni di a e p, a in `let b = a`, il let locale di a, a e p nella chiusura.
+	tests := []struct {
+		chain []ast.Binding
+	}{
+		{[]ast.Binding{{Scope: ast.GlobalBinding, Slot: 0}}},
+		{[]ast.Binding{{Scope: ast.LocalBinding, Slot: 0}}},
+		{[]ast.Binding{{Scope: ast.LocalBinding, Slot: 2}, {Scope: ast.GlobalBinding, Depth: 1, Slot: 0}}},
+		{[]ast.Binding{{Scope: ast.LocalBinding, Slot: 2}}},
+		{[]ast.Binding{{Scope: ast.CapturedBinding, Depth: 1, Slot: 2}, {Scope: ast.GlobalBinding, Depth: 2, Slot: 0}}},
+		{[]ast.Binding{{Scope: ast.CapturedBinding, Depth: 1, Slot: 0}}},
+	}
+	if len(uses) != len(tests) {
+		t.Fatalf("expected %d identifiers, got %d", len(tests), len(uses))
+	}
+
+	for i, tt := range tests {
+		b := uses[i].Binding
+		for j, want := range tt.chain {
+			if b == nil {
+				t.Fatalf("%s #%d: chain too short at %d", uses[i].Value, i, j)
+			}
+			got := *b
+			got.Shadowed = nil
+			if got != want {
+				t.Errorf("%s #%d, link %d: got=%+v, want=%+v", uses[i].Value, i, j, got, want)
+			}
+			b = b.Shadowed
+		}
+		if b != nil {
+			t.Errorf("%s #%d: chain too long: %+v", uses[i].Value, i, *b)
+		}
+	}
+}
+
+func TestUnresolvedNames(t *testing.T) {
+	tests := []struct {
+		input   string
+		code    string
+		message string
+		line    int
+		column  int
+		fix     string
+	}{
+		{"let x = 1; y", CodeUnresolvedName, "identifier not found: y", 1, 12, ""},
+		{"let count = 1;\ncont + 1", CodeUnresolvedName, "identifier not found: cont", 2, 1, "count"},
+		{"fn() { missing }", CodeUnresolvedName, "identifier not found: missing", 1, 8, ""},
+		{"z = 5", CodeUndeclaredAssignee, "assignment to undeclared identifier: z", 1, 3, ""},
+		{"z += 5", CodeUndeclaredAssignee, "assignment to undeclared identifier: z", 1, 3, ""},
+		{"len = 5", CodeUndeclaredAssignee, "assignment to undeclared identifier: len", 1, 5, ""},
+		{"let count = 0;\ncont += 1", CodeUndeclaredAssignee, "assignment to undeclared identifier: cont", 2, 6, "count"},
+		{"try { 1 } catch (e) { 2 }; e", CodeUnresolvedName, "identifier not found: e", 1, 28, ""},
+	}
+
+	for _, tt := range tests {
+		diags := New().Resolve(parse(tt.input))
+		if len(diags) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got %d: %v", tt.input, len(diags), diags)
+			continue
+		}
+		d := diags[0]
+		if d.Code != tt.code || d.Message != tt.message {
+			t.Errorf("%q: wrong diagnostic. got=%s %q", tt.input, d.Code, d.Message)
+		}
+		if d.Span.Start.Line != tt.line || d.Span.Start.Column != tt.column {
+			t.Errorf("%q: wrong position. got=%s", tt.input, d.Span.Start)
+		}
+		switch {
+		case tt.fix == "" && d.Fix != nil:
+			t.Errorf("%q: unexpected fix %+v", tt.input, d.Fix)
+		case tt.fix != "" && (d.Fix == nil || d.Fix.Replacement != tt.fix):
+			t.Errorf("%q: wrong fix. got=%+v, want %q", tt.input, d.Fix, tt.fix)
+		}
+	}
+}
+
+func TestGlobalsPersistAcrossPrograms(t *testing.T) {
+	r := New()
+	env := object.NewEnvironment()
+
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 10;", "10"},
+		{"let f = fn(y) { x + y };", "fn(y) {\n(x + y)\n}"},
+		{"f(5)", "15"},
+		{"x = 1; f(5)", "6"},
+	}
+
+	for _, tt := range inputs {
+		program := parse(tt.input)
+		if diags := r.Resolve(program); len(diags) != 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		result := evaluator.Run(program, env)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: got %q, want %q", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+// TestResolvedEvaluation esegue gli stessi programmi con e senza risoluzione
+// statica: i risultati e gli errori a runtime devono coincidere.
+func TestResolvedEvaluation(t *testing.T) {
+	tests := []string{
+		"let x = 5; let y = x * 2; y",
+		"let x = 1; x = x + 1; x += 10; x",
+		"let x = 1; if (true) { let x = 2 }; x",
+		"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } sum += i }; sum",
+		"let s = 0; for (let i = 0; i < 100; i += 1) { if (i == 5) { break } s += i }; s",
+		"let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib(15)",
+		"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3)",
+		"let counter = fn() { let c = 0; fn() { c += 1; c } }; let next = counter(); next(); next(); next()",
+		"let f = fn() { let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(10) }; f()",
+		"let greet = fn(name, greeting = \"Hello\") { greeting + \", \" + name }; greet(\"Monkey\")",
+		"let f = fn(x, y = x * 2) { y }; f(4)",
+		"let count = fn(first, ...rest) { len(rest) + 1 }; count(1, 2, 3)",
+		"let f = fn(x) { x }; f(1, 2)",
+		"let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a",
+		"try { throw 42 } catch (e) { e + 1 } finally { 99 }",
+		"let fs = []; for (let i = 0; i < 3; i += 1) { try { throw i } catch (e) { fs = push(fs, fn() { e }) } }; [fs[0](), fs[1](), fs[2]()]",
+		"let f = fn() { try { throw 5 } catch (e) { let y = e * 2; fn() { y + e } } }; f()()",
+		"let e = 1; try { throw 2 } catch (e) { e = e + 10 }; e",
+		"let inner = fn(x) { x + true }; let outer = fn(x) { inner(x) }; outer(1)",
+		"let f = fn() { g() }; let g = fn() { 7 }; f()",
+		"let f = fn() { g() }; f(); let g = fn() { 7 }",
+		"puts(len); let len = 5; len",
+		"let loop = fn(n, acc) { if (n == 0) { return acc; } loop(n - 1, acc + n) }; loop(100000, 0)",
+		"let a = fn(x) { x + true }; let b = fn(x) { a(x) }; let c = fn(x) { b(x) }; c(1)",
+		"let x = 5; let f = fn() { x += 1; let x = 10; x }; [f(), x]",
+		"let a = 1; let f = fn() { let b = a; let a = 2; b }; f()",
+		"let g = fn() { let a = 1; fn() { let b = a; let a = 2; [b, a] } }; g()()",
+		"let g = fn() { let a = 1; let h = fn() { a }; let r = h(); let a = 2; [r, h()] }; g()",
+		"let f = fn() { let r = []; for (let i = 0; i < 3; i += 1) { r = push(r, y); let y = i } r }; let y = 7; f()",
+		"let f = fn() { w = 1; let w = 2 }; f()",
+		"let k = 4; try { throw 1 } catch (e) { let k = k + e; k }",
+		"let parse = fn(s) { if (s == \"\") { return error(\"empty\") } int(s) }; let double = fn(s) { parse(s)? * 2 }; double(\"\")",
+	}
+
+	for _, input := range tests {
+		want := describe(evaluator.Run(parse(input), object.NewEnvironment()))
+
+		program := parse(input)
+		if diags := New().Resolve(program); len(diags) != 0 {
+			t.Errorf("%q: unexpected diagnostics: %v", input, diags)
+			continue
+		}
+		got := describe(evaluator.Run(program, object.NewEnvironment()))
+		if got != want {
+			t.Errorf("%q:\nresolved: %s\nby name:  %s", input, got, want)
+		}
+	}
+}
@@ -1,12 +1,51 @@
 package token
 
+import "fmt"
+
 // TokenType rappresenta il tipo di token come stringa
 type TokenType string
 
+// Position individua un punto nel sorgente. Line e Column partono da 1; Column conta i
+// caratteri (rune) e non i byte, mentre Offset è la distanza in byte dall'inizio dell'input.
+type Position struct {
+	Filename string // Il nome del file, vuoto se il sorgente non proviene da un file
+	Line     int
+	Column   int
+	Offset   int
+}
+
+// IsValid indica se la posizione è stata impostata (la posizione zero non è valida).
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String restituisce la posizione nel formato "file:riga:colonna", oppure
+// "riga:colonna" se il nome del file non è noto.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Token rappresenta un singolo token con il suo tipo e valore letterale
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // La posizione del primo carattere del token
+	End     Position // La posizione subito dopo l'ultimo carattere del token
+	// Problem spiega perché un token ILLEGAL non è valido (es. "unterminated string literal").
+	// È vuoto per gli altri token e per un carattere che semplicemente non fa parte del linguaggio.
+	Problem string
+	// Trivia contiene i commenti (token COMMENT) che precedono il token nel sorgente.
+	// Viene popolato solo se il lexer è stato creato con l'opzione lexer.WithComments.
+	Trivia []Token
 }
 
 // Definizione dei token come costanti
@@ -14,10 +53,13 @@ const (
 	// Tokeni speciali
 	ILLEGAL = "ILLEGAL" // Token non riconosciuto
 	EOF     = "EOF"     // Fine del file/input
+	COMMENT = "COMMENT" // Commento "// ..." o "/* ... */", usato solo come trivia
 
 	// Identificatori e letterali
-	IDENT = "IDENT" // Identificatore, es: variabile
-	INT   = "INT"   // Intero
+	IDENT  = "IDENT"  // Identificatore, es: variabile
+	INT    = "INT"    // Intero
+	FLOAT  = "FLOAT"  // Numero in virgola mobile
+	STRING = "STRING" // Stringa racchiusa tra doppi apici
 
 	// Operatori
 	ASSIGN       = "="
@@ -28,6 +70,27 @@ const (
 	FORWARDSLASH = "/"
 	LT           = "<"
 	GT           = ">"
+	PERCENT      = "%"
+	POWER        = "**"
+	TILDE        = "~"
+
+	// Operatori di assegnamento composto
+	PLUS_ASSIGN    = "+="
+	MINUS_ASSIGN   = "-="
+	STAR_ASSIGN    = "*="
+	SLASH_ASSIGN   = "/="
+	PERCENT_ASSIGN = "%="
+
+	// Operatori bit a bit
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	// Operatori logici (con valutazione cortocircuitata)
+	AND = "&&"
+	OR  = "||"
 
 	// Operatori di confronto
 	EQ     = "==" // uguale a
@@ -35,13 +98,20 @@ const (
 	GT_EQ  = ">=" // maggiore o uguale a
 	LT_EQ  = "<=" // minore o uguale a
 
+	// Operatori postfissi
+	QUESTION = "?" // propaga un valore di errore: "f()?"
+
 	// Delimitatori
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 
 	// Parole chiave
 	FUNCTION = "FUNCTION"
@@ -51,17 +121,33 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
 )
 
 // keywords è una mappa che associa identificatori testuali alle parole chiave corrispondenti
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
 }
 
 // LookupIdent verifica se un identificatore è una parola chiave o un identificatore generico
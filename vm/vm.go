@@ -0,0 +1,892 @@
+// File: vm/vm.go
+
+/*
+Package vm esegue il bytecode prodotto dal package compiler su una macchina a
+stack. I valori sono gli stessi object.Object dell'evaluator, e le operazioni
+sui valori sono delegate alle stesse funzioni (evaluator.InfixOperation e
+simili), così che i due motori diano gli stessi risultati e gli stessi errori.
+*/
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"monkey-interpreter/code"
+	"monkey-interpreter/compiler"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/object"
+	"monkey-interpreter/token"
+)
+
+// GlobalsSize è il numero massimo di variabili globali, pari agli indirizzi
+// di un operando di due byte.
+const GlobalsSize = 65536
+
+// initialStackSize è la dimensione iniziale dello stack, che cresce se serve.
+const initialStackSize = 2048
+
+// ctxCheckInterval indica ogni quante istruzioni controllare il contesto.
+const ctxCheckInterval = 1024
+
+// Oggetti singleton condivisi con l'evaluator: i confronti per identità
+// (es. `true == true`) devono dare lo stesso risultato nei due motori.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// operators associa gli opcode agli operatori dell'evaluator.
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpMinus:        "-",
+	code.OpBang:         "!",
+	code.OpBitNot:       "~",
+}
+
+// handler è un gestore di errori installato da OpPushHandler.
+type handler struct {
+	ip    int // Dove riprendere l'esecuzione
+	sp    int // L'altezza dello stack da ripristinare
+	loops int // Quanti cicli erano aperti
+}
+
+// Frame è l'attivazione di una funzione: le sue variabili locali occupano gli
+// slot dello stack a partire da basePointer.
+type Frame struct {
+	cl          *object.Closure
+	ip          int // La prossima istruzione da eseguire
+	basePointer int
+	callSite    int // La posizione dell'OpCall nel chiamante, per la traccia
+	handlers    []handler
+	loops       []int // L'altezza dello stack all'ingresso di ogni ciclo aperto
+
+	// Se il frame è stato riusato da chiamate in coda, entry è la funzione
+	// chiamata in callSite e tailPos la posizione dell'ultima chiamata in coda.
+	entry   *object.Closure
+	tailPos token.Position
+}
+
+/*
+VM esegue un Bytecode. I globali possono essere condivisi tra macchine
+successive (vedi NewWithGlobalsStore), come fa il REPL.
+
+I limiti sono quelli dell'evaluator, con due differenze: MaxSteps conta le
+istruzioni eseguite invece dei nodi valutati, e MaxMemory non è supportato
+(SetLimits lo rifiuta).
+Una VM non va usata da più goroutine contemporaneamente.
+*/
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // Punta alla prossima posizione libera: il valore in cima è stack[sp-1]
+
+	frames []*Frame
+
+	limits evaluator.Limits
+	ctx    context.Context
+	steps  int64
+
+	// current è vero tra OpCurrent e la lettura di variabile che lo segue.
+	current bool
+}
+
+// New crea una macchina che esegue bytecode con globali nuovi.
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore crea una macchina che usa globals come memoria dei globali.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+		NumLocals:    len(bytecode.LocalNames),
+		LocalNames:   bytecode.LocalNames,
+	}
+	mainFrame := &Frame{cl: &object.Closure{Fn: mainFn}, callSite: -1}
+
+	vm := &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+		stack:       make([]object.Object, initialStackSize),
+		frames:      []*Frame{mainFrame},
+		limits:      evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth},
+		ctx:         context.Background(),
+	}
+	vm.ensureStack(mainFn.NumLocals)
+	vm.sp = mainFn.NumLocals
+	return vm
+}
+
+// SetLimits imposta i limiti dell'esecuzione. La VM non stima la memoria allocata:
+// una quota MaxMemory viene rifiutata con un errore, lasciando i limiti invariati,
+// invece di essere ignorata in silenzio.
+func (vm *VM) SetLimits(limits evaluator.Limits) error {
+	if limits.MaxMemory != 0 {
+		return fmt.Errorf("MaxMemory is not supported by the VM")
+	}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = evaluator.DefaultMaxDepth
+	}
+	vm.limits = limits
+	return nil
+}
+
+/*
+Run esegue il programma e ne restituisce il valore, oppure un *object.Error.
+Come evaluator.Interpreter.Run, si interrompe quando ctx viene annullato o
+scade, e trasforma un guasto imprevisto in un errore InternalError.
+*/
+func (vm *VM) Run(ctx context.Context) (result object.Object) {
+	if vm.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, vm.limits.Timeout)
+		defer cancel()
+	}
+	vm.ctx = ctx
+	vm.steps = 0
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{Kind: object.InternalError, Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+
+	if ctx.Err() != nil {
+		return vm.contextError()
+	}
+	return vm.run()
+}
+
+// Steps restituisce il numero di istruzioni eseguite dall'ultima chiamata a Run.
+func (vm *VM) Steps() int64 {
+	return vm.steps
+}
+
+// run è il ciclo principale: legge un'istruzione, la esegue, e in caso di errore
+// cerca un gestore risalendo i frame.
+func (vm *VM) run() object.Object {
+	for {
+		frame := vm.frames[len(vm.frames)-1]
+		ins := frame.cl.Fn.Instructions
+		ip := frame.ip
+		op := code.Opcode(ins[ip])
+		frame.ip++
+
+		var err *object.Error
+		if err = vm.step(); err != nil {
+			if result := vm.raise(err, ip); result != nil {
+				return result
+			}
+			continue
+		}
+
+		switch op {
+		case code.OpConstant:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(vm.constants[index])
+
+		case code.OpTrue:
+			vm.push(True)
+		case code.OpFalse:
+			vm.push(False)
+		case code.OpNull:
+			vm.push(Null)
+		case code.OpPop:
+			vm.sp--
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpLessEqual,
+			code.OpGreaterThan, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			var result object.Object
+			result, err = vm.binaryOperation(op, left, right)
+			if err == nil {
+				vm.push(result)
+			}
+
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			result := evaluator.PrefixOperation(operators[op], vm.pop())
+			if err = asError(result); err == nil {
+				vm.push(result)
+			}
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[ip+1:]))
+		case code.OpJumpNotTruthy:
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+		case code.OpJumpNotTruthyOrPop:
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.stack[vm.sp-1]) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.sp--
+			}
+		case code.OpJumpTruthyOrPop:
+			frame.ip += 2
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.sp--
+			}
+		case code.OpJumpNotErrorValue:
+			frame.ip += 2
+			if vm.stack[vm.sp-1].Type() != object.ERROR_VALUE_OBJ {
+				frame.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+		case code.OpJumpIfLocalSet:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 3
+			value := vm.stack[frame.basePointer+slot]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value != nil {
+				frame.ip = int(code.ReadUint16(ins[ip+2:]))
+			}
+		case code.OpJumpIfFreeSet:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 3
+			if frame.cl.Free[index].Value != nil {
+				frame.ip = int(code.ReadUint16(ins[ip+2:]))
+			}
+
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			value := vm.globals[index]
+			switch {
+			case value != nil:
+			case vm.current:
+				// Una funzione built-in non si può riassegnare: resta non dichiarata.
+				err = vm.missing(vm.globalName(int(index)))
+			default:
+				value, err = vm.lookupBuiltin(int(index))
+			}
+			vm.current = false
+			if err == nil {
+				vm.push(value)
+			}
+		case code.OpSetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[index] = vm.stack[vm.sp-1]
+		case code.OpAssignGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			if vm.globals[index] == nil {
+				err = undeclared(vm.globalName(int(index)))
+			} else {
+				vm.globals[index] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpGetLocal:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			value := vm.stack[frame.basePointer+slot]
+			if value == nil {
+				err = vm.missing(frame.cl.Fn.LocalNames[slot])
+			} else {
+				vm.push(value)
+			}
+			vm.current = false
+		case code.OpSetLocal:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.stack[frame.basePointer+slot] = vm.stack[vm.sp-1]
+		case code.OpAssignLocal:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			if vm.stack[frame.basePointer+slot] == nil {
+				err = undeclared(frame.cl.Fn.LocalNames[slot])
+			} else {
+				vm.stack[frame.basePointer+slot] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpGetFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			if value := frame.cl.Free[index].Value; value == nil {
+				err = vm.missing(frame.cl.Fn.FreeNames[index])
+			} else {
+				vm.push(value)
+			}
+			vm.current = false
+		case code.OpAssignFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			cell := frame.cl.Free[index]
+			if cell.Value == nil {
+				err = undeclared(frame.cl.Fn.FreeNames[index])
+			} else {
+				cell.Value = vm.stack[vm.sp-1]
+			}
+
+		case code.OpCurrent:
+			vm.current = true
+
+		case code.OpMakeCell:
+			slot := frame.basePointer + int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			if _, ok := vm.stack[slot].(*object.Cell); ok {
+				// Il blocco viene eseguito di nuovo (es. un catch in un ciclo):
+				// le chiusure create prima devono tenere la loro cella.
+				vm.stack[slot] = &object.Cell{}
+			} else {
+				vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+			}
+		case code.OpGetCell:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			if value := vm.stack[frame.basePointer+slot].(*object.Cell).Value; value == nil {
+				err = vm.missing(frame.cl.Fn.LocalNames[slot])
+			} else {
+				vm.push(value)
+			}
+			vm.current = false
+		case code.OpSetCell:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.stack[frame.basePointer+slot].(*object.Cell).Value = vm.stack[vm.sp-1]
+		case code.OpAssignCell:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			cell := vm.stack[frame.basePointer+slot].(*object.Cell)
+			if cell.Value == nil {
+				err = undeclared(frame.cl.Fn.LocalNames[slot])
+			} else {
+				cell.Value = vm.stack[vm.sp-1]
+			}
+		case code.OpLoadCell:
+			slot := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.push(vm.stack[frame.basePointer+slot])
+		case code.OpLoadFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.push(frame.cl.Free[index])
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-2*n, vm.sp)
+			vm.sp -= 2 * n
+			if err == nil {
+				vm.push(hash)
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result := evaluator.IndexOperation(left, index)
+			if err = asError(result); err == nil {
+				vm.push(result)
+			}
+		case code.OpIndexCurrent:
+			index := vm.pop()
+			container := vm.pop()
+			var current object.Object
+			current, err = currentElement(container, index)
+			if err == nil {
+				vm.push(current)
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			container := vm.pop()
+			if err = setElement(container, index, value); err == nil {
+				vm.push(value)
+			}
+		case code.OpDup2:
+			vm.push(vm.stack[vm.sp-2])
+			vm.push(vm.stack[vm.sp-2])
+
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.call(numArgs, ip)
+		case code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.tailCall(numArgs, ip)
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if len(vm.frames) == 1 {
+				return returnValue
+			}
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.sp = frame.basePointer - 1
+			vm.push(returnValue)
+		case code.OpClosure:
+			index := code.ReadUint16(ins[ip+1:])
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			free := make([]*object.Cell, numFree)
+			for i := range free {
+				free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+			}
+			vm.sp -= numFree
+			vm.push(&object.Closure{Fn: vm.constants[index].(*object.CompiledFunction), Free: free})
+
+		case code.OpEnterLoop:
+			frame.loops = append(frame.loops, vm.sp)
+		case code.OpExitLoop:
+			frame.loops = frame.loops[:len(frame.loops)-1]
+		case code.OpUnwindLoop:
+			vm.sp = frame.loops[len(frame.loops)-1]
+		case code.OpPushHandler:
+			target := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			frame.handlers = append(frame.handlers, handler{ip: target, sp: vm.sp, loops: len(frame.loops)})
+		case code.OpPopHandler:
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+		case code.OpThrow:
+			value := vm.pop()
+			err = &object.Error{Message: value.Inspect(), Value: value}
+		case code.OpRethrow:
+			err = vm.pop().(*object.Error)
+		case code.OpCaught:
+			vm.stack[vm.sp-1] = evaluator.CaughtValue(vm.stack[vm.sp-1].(*object.Error))
+
+		default:
+			return &object.Error{Kind: object.InternalError, Message: fmt.Sprintf("internal error: unknown opcode %d", op)}
+		}
+
+		if err != nil {
+			if result := vm.raise(err, ip); result != nil {
+				return result
+			}
+		}
+	}
+}
+
+/*
+raise propaga err, prodotto dall'istruzione in posizione ip del frame corrente.
+Se l'errore non ha ancora una posizione, riceve quella dell'istruzione.
+
+Un errore del programma (RuntimeError) riprende dal gestore più recente, con
+lo stack riportato all'altezza che aveva quando il gestore è stato installato.
+Gli altri errori, come le funzioni senza gestori, chiudono il frame e passano al
+chiamante, aggiungendo un frame alla traccia come fa l'evaluator.
+Restituisce err se nessun gestore lo intercetta, nil altrimenti.
+*/
+func (vm *VM) raise(err *object.Error, ip int) *object.Error {
+	frame := vm.frames[len(vm.frames)-1]
+	if !err.Pos.IsValid() {
+		err.Pos, err.End = frame.cl.Fn.SourceMap.Lookup(ip)
+	}
+
+	for {
+		frame := vm.frames[len(vm.frames)-1]
+		if err.Kind == object.RuntimeError && len(frame.handlers) > 0 {
+			h := frame.handlers[len(frame.handlers)-1]
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+			frame.loops = frame.loops[:h.loops]
+			vm.sp = h.sp
+			vm.push(err)
+			frame.ip = h.ip
+			return nil
+		}
+
+		if len(vm.frames) == 1 {
+			return err
+		}
+
+		caller := vm.frames[len(vm.frames)-2]
+		pos, _ := caller.cl.Fn.SourceMap.Lookup(frame.callSite)
+		if frame.entry != nil {
+			err.Trace = append(err.Trace, object.Frame{Function: functionName(frame.cl), Pos: frame.tailPos})
+			err.Trace = append(err.Trace, object.Frame{Function: functionName(frame.entry), Pos: pos})
+		} else {
+			err.Trace = append(err.Trace, object.Frame{Function: functionName(frame.cl), Pos: pos})
+		}
+
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		vm.sp = frame.basePointer - 1
+	}
+}
+
+// call chiama la funzione che si trova sotto i numArgs argomenti in cima allo
+// stack; ip è la posizione dell'OpCall.
+func (vm *VM) call(numArgs int, ip int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs, ip)
+	case *object.Builtin:
+		// La funzione riceve una copia: potrebbe conservare la slice degli argomenti.
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp -= numArgs + 1
+
+		result := callee.Fn(args...)
+		if result == nil {
+			result = Null
+		}
+		if err := asError(result); err != nil {
+			return err
+		}
+		vm.push(result)
+		return nil
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+/*
+tailCall esegue una chiamata in coda: se la funzione chiamata è una chiusura,
+il suo frame prende il posto di quello corrente, così che una ricorsione in coda
+non faccia crescere lo stack né consumi la profondità massima. Come
+nell'evaluator, la traccia di un errore mostra solo l'ultima chiamata in coda e
+la chiamata originale. In tutti gli altri casi è una chiamata normale, e
+l'OpReturnValue che segue restituisce il risultato.
+*/
+func (vm *VM) tailCall(numArgs int, ip int) *object.Error {
+	frame := vm.frames[len(vm.frames)-1]
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || len(vm.frames) == 1 {
+		return vm.call(numArgs, ip)
+	}
+
+	// Gli errori di arità nascono mentre il chiamante è ancora attivo.
+	fn := cl.Fn
+	if numArgs < fn.NumRequired || (!fn.HasRest && numArgs > fn.NumParameters) {
+		err := newError("wrong number of arguments. got=%d, want=%s", numArgs, arity(fn))
+		return vm.callError(err, cl, ip)
+	}
+
+	entry := frame.entry
+	if entry == nil {
+		entry = frame.cl
+	}
+	tailPos, _ := frame.cl.Fn.SourceMap.Lookup(ip)
+
+	// Sposta la funzione e gli argomenti al posto del frame corrente.
+	base := frame.basePointer - 1
+	copy(vm.stack[base:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = base + 1 + numArgs
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	if err := vm.callClosure(cl, numArgs, frame.callSite); err != nil {
+		return err
+	}
+	replaced := vm.frames[len(vm.frames)-1]
+	replaced.entry, replaced.tailPos = entry, tailPos
+	return nil
+}
+
+// callClosure apre il frame di cl: gli argomenti già sullo stack diventano i
+// primi slot locali, quelli in eccesso finiscono nel parametro variadico e gli
+// slot restanti partono vuoti.
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, ip int) *object.Error {
+	fn := cl.Fn
+	if len(vm.frames)-1 >= vm.limits.MaxDepth {
+		err := &object.Error{Kind: object.DepthLimitExceeded, Message: fmt.Sprintf("maximum call depth exceeded: %d", vm.limits.MaxDepth)}
+		return vm.callError(err, cl, ip)
+	}
+	if numArgs < fn.NumRequired || (!fn.HasRest && numArgs > fn.NumParameters) {
+		err := newError("wrong number of arguments. got=%d, want=%s", numArgs, arity(fn))
+		return vm.callError(err, cl, ip)
+	}
+
+	basePointer := vm.sp - numArgs
+	vm.ensureStack(basePointer + fn.NumLocals)
+
+	first := numArgs
+	if fn.HasRest {
+		rest := []object.Object{}
+		if numArgs > fn.NumParameters {
+			rest = append(rest, vm.stack[basePointer+fn.NumParameters:vm.sp]...)
+		}
+		for i := numArgs; i < fn.NumParameters; i++ {
+			vm.stack[basePointer+i] = nil
+		}
+		vm.stack[basePointer+fn.NumParameters] = &object.Array{Elements: rest}
+		first = fn.NumParameters + 1
+	}
+	for i := first; i < fn.NumLocals; i++ {
+		vm.stack[basePointer+i] = nil
+	}
+
+	vm.frames = append(vm.frames, &Frame{cl: cl, basePointer: basePointer, callSite: ip})
+	vm.sp = basePointer + fn.NumLocals
+	return nil
+}
+
+// callError completa un errore avvenuto entrando in cl: come nell'evaluator, la
+// traccia contiene già il frame della funzione chiamata.
+func (vm *VM) callError(err *object.Error, cl *object.Closure, ip int) *object.Error {
+	frame := vm.frames[len(vm.frames)-1]
+	pos, _ := frame.cl.Fn.SourceMap.Lookup(ip)
+	err.Trace = append(err.Trace, object.Frame{Function: functionName(cl), Pos: pos})
+	return err
+}
+
+// arity descrive il numero di argomenti accettati da fn, es. "2", "1..3" o "1+".
+func arity(fn *object.CompiledFunction) string {
+	switch {
+	case fn.HasRest:
+		return fmt.Sprintf("%d+", fn.NumRequired)
+	case fn.NumRequired == fn.NumParameters:
+		return fmt.Sprintf("%d", fn.NumRequired)
+	default:
+		return fmt.Sprintf("%d..%d", fn.NumRequired, fn.NumParameters)
+	}
+}
+
+func functionName(cl *object.Closure) string {
+	if cl.Fn.Name == "" {
+		return "<anonymous>"
+	}
+	return cl.Fn.Name
+}
+
+/*
+binaryOperation applica un operatore binario. Gli interi piccoli, il caso più
+comune nei cicli e nelle funzioni ricorsive, sono gestiti qui senza passare per
+l'evaluator; tutto il resto (overflow compreso) è delegato a InfixOperation.
+*/
+func (vm *VM) binaryOperation(op code.Opcode, left, right object.Object) (object.Object, *object.Error) {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			a, b := l.Value, r.Value
+			switch op {
+			case code.OpAdd:
+				if sum := a + b; (a^sum)&(b^sum) >= 0 {
+					return object.NewInteger(sum), nil
+				}
+			case code.OpSub:
+				if diff := a - b; (a^b)&(a^diff) >= 0 {
+					return object.NewInteger(diff), nil
+				}
+			case code.OpEqual:
+				return nativeBool(a == b), nil
+			case code.OpNotEqual:
+				return nativeBool(a != b), nil
+			case code.OpLessThan:
+				return nativeBool(a < b), nil
+			case code.OpLessEqual:
+				return nativeBool(a <= b), nil
+			case code.OpGreaterThan:
+				return nativeBool(a > b), nil
+			case code.OpGreaterEqual:
+				return nativeBool(a >= b), nil
+			}
+		}
+	}
+
+	result := evaluator.InfixOperation(operators[op], left, right)
+	if err := asError(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// buildHash crea una mappa dalle coppie chiave/valore in stack[start:end].
+func (vm *VM) buildHash(start, end int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", vm.stack[i].Type())
+		}
+		hash.Set(key, vm.stack[i+1])
+	}
+	return hash, nil
+}
+
+// currentElement verifica un bersaglio di assegnamento con indice e ne
+// restituisce il valore attuale (null per una chiave assente).
+func currentElement(container, index object.Object) (object.Object, *object.Error) {
+	switch container := container.(type) {
+	case *object.Array:
+		idx, err := arrayIndex(container, index)
+		if err != nil {
+			return nil, err
+		}
+		return container.Elements[idx], nil
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", index.Type())
+		}
+		if value, found := container.Get(key); found {
+			return value, nil
+		}
+		return Null, nil
+	default:
+		return nil, newError("index assignment not supported: %s", container.Type())
+	}
+}
+
+// setElement scrive container[index] = value.
+func setElement(container, index, value object.Object) *object.Error {
+	switch container := container.(type) {
+	case *object.Array:
+		idx, err := arrayIndex(container, index)
+		if err != nil {
+			return err
+		}
+		container.Elements[idx] = value
+		return nil
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		container.Set(key, value)
+		return nil
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+}
+
+// arrayIndex converte index in una posizione valida di array; i negativi contano dalla fine.
+func arrayIndex(array *object.Array, index object.Object) (int64, *object.Error) {
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newError("array index must be INTEGER, got %s", index.Type())
+	}
+	idx := integer.Value
+	length := int64(len(array.Elements))
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return 0, newError("index out of range: %d (length %d)", integer.Value, length)
+	}
+	return idx, nil
+}
+
+// lookupBuiltin risolve un globale mai definito: come nell'evaluator, le
+// variabili dell'utente hanno la precedenza sulle funzioni built-in.
+func (vm *VM) lookupBuiltin(index int) (object.Object, *object.Error) {
+	name := vm.globalName(index)
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return builtin, nil
+	}
+	return nil, notFound(name)
+}
+
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+// step conta un'istruzione eseguita e verifica il limite di passi e il contesto.
+func (vm *VM) step() *object.Error {
+	vm.steps++
+	if vm.limits.MaxSteps > 0 && vm.steps > vm.limits.MaxSteps {
+		return &object.Error{Kind: object.StepLimitExceeded, Message: fmt.Sprintf("step limit exceeded: %d steps", vm.limits.MaxSteps)}
+	}
+	if vm.steps%ctxCheckInterval == 0 && vm.ctx.Err() != nil {
+		return vm.contextError()
+	}
+	return nil
+}
+
+// contextError traduce il motivo per cui il contesto è terminato in un errore.
+func (vm *VM) contextError() *object.Error {
+	if errors.Is(vm.ctx.Err(), context.DeadlineExceeded) {
+		return &object.Error{Kind: object.DeadlineExceeded, Message: "execution deadline exceeded"}
+	}
+	return &object.Error{Kind: object.Canceled, Message: "execution canceled"}
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.ensureStack(vm.sp + 1)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// ensureStack fa crescere lo stack finché ha almeno size posizioni.
+func (vm *VM) ensureStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	newSize := 2 * len(vm.stack)
+	for newSize < size {
+		newSize *= 2
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+// asError restituisce obj come errore, o nil se non lo è.
+func asError(obj object.Object) *object.Error {
+	err, _ := obj.(*object.Error)
+	return err
+}
+
+func nativeBool(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func notFound(name string) *object.Error {
+	return newError("identifier not found: %s", name)
+}
+
+func undeclared(name string) *object.Error {
+	return newError("assignment to undeclared identifier: %s", name)
+}
+
+// missing è l'errore di una variabile letta senza valore: per un assegnamento
+// composto (dopo OpCurrent) è quello di un assegnamento, come per "=".
+func (vm *VM) missing(name string) *object.Error {
+	if vm.current {
+		return undeclared(name)
+	}
+	return notFound(name)
+}
@@ -0,0 +1,288 @@
+// vm/vm_test.go
+package vm
+
+import (
+	"context"
+	"monkey-interpreter/compiler"
+	"monkey-interpreter/evaluator"
+	"monkey-interpreter/lexer"
+	"monkey-interpreter/object"
+	"monkey-interpreter/parser"
+	"testing"
+	"time"
+)
+
+func runVM(t *testing.T, input string, limits evaluator.Limits) object.Object {
+	t.Helper()
+	program := parser.New(lexer.New(input)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+	machine := New(comp.Bytecode())
+	if err := machine.SetLimits(limits); err != nil {
+		t.Fatalf("SetLimits: %s", err)
+	}
+	return machine.Run(context.Background())
+}
+
+func runEval(input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return evaluator.Run(program, object.NewEnvironment())
+}
+
+// describe riassume un risultato per il confronto tra i due motori: per un
+// errore include posizione e traccia, oltre al messaggio.
+func describe(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return err.Inspect() + "\n" + err.Traceback()
+	}
+	return obj.Inspect()
+}
+
+// TestParity esegue gli stessi programmi con l'evaluator e con la VM: risultati
+// ed errori devono coincidere, posizioni e tracce comprese.
+func TestParity(t *testing.T) {
+	tests := []string{
+		// Aritmetica e confronti
+		"1 + 2 * 3 - 4 / 2",
+		"7 % 3; -7 % 3; 2 ** 10; 2 ** -1",
+		"6 & 3 | 8 ^ 1; ~5; 1 << 4; -16 >> 2",
+		"9223372036854775807 + 1",
+		"-9223372036854775807 - 10",
+		"9223372036854775807 * 2",
+		"1.5 + 2; 3 / 2.0",
+		"1 < 2; 2 <= 2; 3 > 4; 4 >= 5; 1 == 1; 1 != 1",
+		`"a" + "b"; "a" == "a"; "a" < "b"`,
+		"true == true; true != false; !true; !!5; !null",
+		"1 / 0",
+		"1 + true",
+		"-true",
+		"a + 1",
+
+		// Variabili, blocchi e condizioni
+		"let x = 5; let y = x * 2; y",
+		"let x = 1; x = x + 1; x += 10; x",
+		"y = 1",
+		"y += 1",
+		"len += 1",
+		"fn() { y *= 2 }()",
+		"let f = fn() { y -= 1; let y = 2 }; f()",
+		"let g = fn() { let h = fn() { z += 1 }; h(); let z = 1 }; g()",
+		"let g = fn() { let c = fn() { w }; try { w += 1 } catch (e) { e[\"message\"] } let w = 0 }; g()",
+		"if (1 < 2) { 10 } else { 20 }",
+		"if (false) { 10 }",
+		"if (true) { }",
+		"",
+		"let x = 1; if (true) { let x = 2 }; x",
+		"true && 5; false && 5; null || 7; 3 || 4",
+		"false || missing",
+
+		// Cicli
+		"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } sum += i }; sum",
+		"let s = 0; for (let i = 0; i < 100; i += 1) { if (i == 5) { break } s += i }; s",
+		"for (let i = 0; i < 3; i += 1) { i }",
+		"let n = 0; for (;;) { n += 1; if (n > 3) { break } }; n",
+		"let r = []; for (let i = 0; i < 3; i += 1) { for (let j = 0; j < 3; j += 1) { if (j == 1) { continue } if (i == 2) { break } r = push(r, [i, j]) } }; r",
+		"let f = fn() { let i = 0; while (true) { i += 1; puts(if (i > 2) { break }) } 7 }; f()",
+		"let r = []; for (let i = 0; i < 4; i += 1) { r = push(r, [i, if (i % 2 == 0) { continue } else { i }]) }; r",
+		"let n = 0; while (true) { n += 1; let h = {\"k\": if (n > 2) { break } else { n }} }; n",
+		"let n = 0; while (n < 10) { n = n + if (n > 4) { break } else { 1 } }; n",
+		"let n = 0; while (true) { n += 1; n > 1 && if (true) { break } }; n",
+		"let a = [0]; let n = 0; while (true) { n += 1; a[if (n > 1) { break } else { 0 }] = n }; [n, a]",
+
+		// Un nome si riferisce alla variabile esterna finché il suo let non viene eseguito
+		"let x = 5; let f = fn() { x += 1; let x = 10; x }; f()",
+		"let x = 5; let f = fn() { x += 1; let x = 10; x }; [f(), x]",
+		"let a = 1; let f = fn() { let b = a; let a = 2; b }; f()",
+		"let f = fn() { let r = []; for (let i = 0; i < 3; i += 1) { r = push(r, y); let y = i } r }; let y = 7; f()",
+		"let g = fn() { let a = 1; fn() { let b = a; let a = 2; [b, a] } }; g()()",
+		"let g = fn() { let a = 1; let h = fn() { a }; let r = h(); let a = 2; [r, h()] }; g()",
+		"let f = fn() { let get = fn() { v }; let r = [get()]; let v = 3; push(r, get()) }; let v = 1; f()",
+		"let f = fn() { z }; f()",
+		"let f = fn() { w = 1; let w = 2 }; f()",
+		"let g = fn(p) { let q = fn() { p + 1 }; let p = 10; q() }; g(1)",
+		"try { throw 1 } catch (e) { let k = k; k }",
+		"let k = 4; try { throw 1 } catch (e) { let k = k + e; k }",
+
+		// Funzioni e chiusure
+		"let add = fn(a, b) { a + b }; add(1, 2)",
+		"let fib = fn(n) { if (n < 2) { return n } fib(n - 1) + fib(n - 2) }; fib(15)",
+		"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3)",
+		"let counter = fn() { let c = 0; fn() { c += 1; c } }; let next = counter(); next(); next(); next()",
+		"let f = fn() { let a = 1; let g = fn() { a = a + 10 }; g(); a }; f()",
+		"let outer = fn() { let a = 1; fn() { fn() { a += 1; a } } }; let inner = outer()(); inner(); inner()",
+		"let f = fn() { let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(10) }; f()",
+		"let greet = fn(name, greeting = \"Hello\") { greeting + \", \" + name }; greet(\"Monkey\"); greet(\"Monkey\", \"Hi\")",
+		"let f = fn(x, y = x * 2) { y }; f(4)",
+		"let count = fn(first, ...rest) { len(rest) + 1 }; count(1, 2, 3); count(1)",
+		"let f = fn(...xs) { xs }; f(); f(1, 2)",
+		"let f = fn(a, b = 1, ...c) { [a, b, c] }; f(1); f(1, 2, 3, 4)",
+		"fn(x) { x }",
+		"let f = fn(x) { x }; f",
+		"let f = fn(x) { x }; f(1, 2)",
+		"let f = fn(x, y = 1) { x }; f()",
+		"let f = fn(x, ...r) { x }; f()",
+		"5(1)",
+		"len(\"abc\"); len(1)",
+		"let len = fn(x) { 42 }; len(\"abc\")",
+		"let f = fn() { return 1; 2 }; f()",
+		"return 5; 6",
+		"let f = fn() { while (true) { return 3 } }; f()",
+
+		// Array e mappe
+		"[1, 2 * 2, 3][1]",
+		"[1, 2, 3][-1]; [1, 2, 3][5]",
+		`let h = {"a": 1, 2: "due", true: 3}; h["a"]; h[2]; h[true]; h["x"]`,
+		"{[1]: 2}",
+		"let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a",
+		`let h = {}; h["x"] = 1; h["x"] += 2; h`,
+		"let a = [1]; a[3] = 2",
+		"let a = 5; a[0] = 1",
+		`let a = [1]; a["x"] = 1`,
+		"1[0]",
+
+		// Eccezioni
+		"try { missing } catch (e) { e[\"message\"] }",
+		"try { throw 42 } catch (e) { e + 1 } finally { 99 }",
+		"try { 1 } finally { 2 }",
+		"try { throw 1 } finally { 2 }",
+		"try { throw \"a\" } catch { 5 }",
+		"let log = []; try { try { throw 1 } finally { log = push(log, \"inner\") } } catch (e) { log = push(log, e) }; log",
+		"let f = fn() { try { return 1 } finally { puts(\"done\") } }; f()",
+		"let f = fn() { try { throw 1 } catch (e) { return e + 1 } finally { 0 } }; f()",
+		"let f = fn() { try { 1 } finally { return 2 } }; f()",
+		"try { throw 1 } catch (e) { throw e + 1 }",
+		"try { throw 1 } catch (e) { throw e + 1 } finally { 3 }",
+		"let n = 0; while (n < 5) { try { n += 1; if (n == 3) { break } } finally { n += 10 } }; n",
+		"let r = []; for (let i = 0; i < 3; i += 1) { try { if (i == 1) { continue } r = push(r, i) } catch (e) { 0 } }; r",
+		"let thrower = fn() { throw {\"code\": 7} }; try { thrower() } catch (e) { e[\"code\"] }",
+		"let f = fn(x) { x + true }; try { [1, f(1)] } catch (e) { e[\"line\"] }",
+		"let fs = []; for (let i = 0; i < 3; i += 1) { try { throw i } catch (e) { fs = push(fs, fn() { e }) } }; [fs[0](), fs[1](), fs[2]()]",
+		"let f = fn() { try { throw 5 } catch (e) { let y = e * 2; fn() { y + e } } }; f()()",
+		"try { 1 + true } catch (e) { e }",
+
+		// Valori di errore
+		"let parse = fn(s) { if (s == \"\") { return error(\"empty input\") } int(s) }; let double = fn(s) { parse(s)? * 2 }; double(\"21\"); double(\"\")",
+		"let e = error(\"boom\", 7); [is_error(e), e[\"message\"], e[\"data\"]]",
+		"unwrap(error(\"bad\"))",
+		"error(\"top\")?; 5",
+
+		// Tracce
+		"let inner = fn(x) { x + true }; let outer = fn(x) { inner(x) }; outer(1)",
+		"let f = fn() { missing }; let g = fn() { f() }; g()",
+		"let f = fn(a) { a }; let g = fn() { f() }; g()",
+		"fn() { 1 + null }()",
+		"let rec = fn(n) { 1 + rec(n + 1) }; rec(0)",
+
+		// Chiamate in coda
+		"let loop = fn(n, acc) { if (n == 0) { return acc; } loop(n - 1, acc + n) }; loop(100000, 0)",
+		"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100001)",
+		"let count = fn(n) { while (true) { if (n == 0) { return \"done\" } return count(n - 1) } }; count(50000)",
+		"let a = fn(x) { x + true }; let b = fn(x) { a(x) }; let c = fn(x) { b(x) }; let d = fn(x) { 1 + c(x) }; d(1)",
+		"let f = fn(x) { x }; let g = fn() { f() }; let h = fn() { g() }; h()",
+		"let f = fn(n) { if (n == 0) { len(1) } else { f(n - 1) } }; f(3)",
+		"let f = fn(n) { try { return g(n) } catch (e) { e } }; let g = fn(n) { throw n }; f(5)",
+		"let f = fn(n) { try { 1 } catch (e) { 2 } finally { return g(n) } }; let g = fn(n) { n * 2 }; f(5)",
+		"let f = fn() { try { throw 1 } catch (e) { return g(e) } }; let g = fn(x) { x + true }; f()",
+		"let f = fn(x = g()) { x }; let g = fn() { missing }; let h = fn() { f() }; h()",
+		"let f = fn(n, ...xs) { if (n == 0) { return xs } f(n - 1, n, n) }; f(5)",
+	}
+
+	for _, input := range tests {
+		want := describe(runEval(input))
+		got := describe(runVM(t, input, evaluator.Limits{}))
+		if got != want {
+			t.Errorf("%q:\nvm:   %s\neval: %s", input, got, want)
+		}
+	}
+}
+
+func TestGlobalsPersistAcrossRuns(t *testing.T) {
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 10;", "10"},
+		{"let f = fn(y) { x + y };", "fn(y) {\n(x + y)\n}"},
+		{"f(5)", "15"},
+		{"x = 1; f(5)", "6"},
+		{"len([1, 2])", "2"},
+	}
+
+	for _, tt := range inputs {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = comp.Constants()
+
+		result := NewWithGlobalsStore(comp.Bytecode(), globals).Run(context.Background())
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: got %q, want %q", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input  string
+		limits evaluator.Limits
+		kind   object.ErrorKind
+	}{
+		{"while (true) { }", evaluator.Limits{MaxSteps: 1000}, object.StepLimitExceeded},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", evaluator.Limits{MaxDepth: 50}, object.DepthLimitExceeded},
+		{"let f = fn() { f() }; f()", evaluator.Limits{MaxSteps: 1000}, object.StepLimitExceeded},
+		{"while (true) { }", evaluator.Limits{Timeout: 20 * time.Millisecond}, object.DeadlineExceeded},
+		{"try { while (true) { } } catch (e) { 1 } finally { 2 }", evaluator.Limits{MaxSteps: 1000}, object.StepLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		result := runVM(t, tt.input, tt.limits)
+		err, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected error, got %s", tt.input, result.Inspect())
+			continue
+		}
+		if err.Kind != tt.kind {
+			t.Errorf("%q: wrong kind. got=%s, want=%s", tt.input, err.Kind, tt.kind)
+		}
+	}
+}
+
+func TestMaxMemoryRejected(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New("let a = [1]; a")).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := New(comp.Bytecode())
+
+	err := machine.SetLimits(evaluator.Limits{MaxMemory: 1 << 20, MaxSteps: 1})
+	if err == nil || err.Error() != "MaxMemory is not supported by the VM" {
+		t.Fatalf("expected MaxMemory to be rejected, got %v", err)
+	}
+	// I limiti restano quelli precedenti: MaxSteps non è stato applicato.
+	if result := machine.Run(context.Background()); result.Inspect() != "[1]" {
+		t.Errorf("limits changed by a rejected SetLimits: %s", result.Inspect())
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	program := parser.New(lexer.New("while (true) { }")).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := New(comp.Bytecode()).Run(ctx)
+	if err, ok := result.(*object.Error); !ok || err.Kind != object.Canceled {
+		t.Fatalf("expected Canceled error, got %s", result.Inspect())
+	}
+}